@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// bytesDisplayStrings shows num as the 8 bytes it occupies in memory in both byte
+// orders and as the int and uint lanes of a little-endian machine (lane 0 first).
+func bytesDisplayStrings(num int64, err error) []string {
+	var le [8]byte
+	binary.LittleEndian.PutUint64(le[:], uint64(num)) //nolint:gosec // reinterpreting bits
+	var be [8]byte
+	binary.BigEndian.PutUint64(be[:], uint64(num)) //nolint:gosec // reinterpreting bits
+	display := []string{
+		"",
+		"Bytes LE: " + hexBytes(le[:]),
+		"Bytes BE: " + hexBytes(be[:]),
+	}
+	for _, width := range []int{8, 16, 32} {
+		var signed, unsigned []string
+		lanes := 64 / width
+		columns := len(fmt.Sprint(-int64(1) << (width - 1)))
+		for lane := range lanes {
+			raw := uint64(num) >> (lane * width) & (1<<width - 1) //nolint:gosec // reinterpreting bits
+			value := int64(raw) << (64 - width) >> (64 - width)   //nolint:gosec // sign extension
+			signed = append(signed, fmt.Sprintf("%*d", columns, value))
+			unsigned = append(unsigned, fmt.Sprintf("%*d", columns, raw))
+		}
+		display = append(display,
+			fmt.Sprintf("int%-3d %s", width, strings.Join(signed, " ")),
+			fmt.Sprintf("uint%-2d %s", width, strings.Join(unsigned, " ")))
+	}
+	display = append(display, bitGrid(num, byteColor)...)
+	if err != nil {
+		display[0] = paint(errorElement, "Last input was invalid")
+	}
+	return display
+}
+
+func hexBytes(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// byteColor alternates colors between bytes on the bit grid so they line up with the hex dump.
+func byteColor(bit int) string {
+	if bit/8%2 == 1 {
+		return colors[accentElement]
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package calculator
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is what a Node of a parsed expression is.
+type Kind int
+
+const (
+	Literal    Kind = iota // a number, character or string literal
+	Identifier             // a variable, ans or a reference to a result such as $3
+	Unary                  // negation or ~, Children holds the operand
+	Binary                 // Children holds the left and right operands
+	Assign                 // Name = Children[0]
+	Call                   // Name(Children...)
+)
+
+var kindNames = []string{"literal", "identifier", "unary", "binary", "assign", "call"}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Node is a read-only view of the tree the parser built for an expression.
+type Node struct {
+	Kind     Kind
+	Op       string // operator of Unary and Binary nodes
+	Name     string // variable of Assign nodes, function of Call nodes
+	Value    string // text of Literal and Identifier nodes
+	Children []Node
+	// Pos is the byte offset in the input of the node's own token: the operator, the
+	// function or variable name or the literal. It is -1 when not known.
+	Pos int
+}
+
+// AST parses input into its public tree.
+func (s *State) AST(input string) (Node, error) {
+	tokens, err := s.Tokenize(input)
+	if err != nil {
+		return Node{}, err
+	}
+	node, err := s.Parse(tokens)
+	if err != nil {
+		return Node{}, err
+	}
+	ast := node.Node()
+	if err := ast.validate(); err != nil {
+		return Node{}, err
+	}
+	cursor := 0
+	ast.locate(input, &cursor)
+	return ast, nil
+}
+
+// validate catches the operators the parser left without operands, as in 1 & -1.
+func (n Node) validate() error {
+	if (n.Kind == Literal || n.Kind == Identifier) && !isOperand(n.Value) {
+		return errors.New("missing operand near " + strconv.Quote(n.Value))
+	}
+	for _, child := range n.Children {
+		if err := child.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isOperand(value string) bool {
+	return value != "" && !slices.Contains(Length1operatorsInfix, Operator(value[0])) &&
+		!slices.Contains(Length2operators, DoubleRuneOperator(value)) && value != string(EXP) && value != string(NOT)
+}
+
+// Node converts the parser's tree, without positions.
+func (n CalcNode) Node() Node {
+	switch {
+	case n.assignment != nil:
+		return Node{Kind: Assign, Name: n.assignment.name, Children: []Node{n.assignment.right.Node()}, Pos: -1}
+	case n.call != nil:
+		children := make([]Node, len(n.call.args))
+		for i, arg := range n.call.args {
+			children[i] = arg.Node()
+		}
+		return Node{Kind: Call, Name: n.call.name, Children: children, Pos: -1}
+	case n.value == nil:
+		return Node{Kind: Literal, Pos: -1}
+	case n.right == nil:
+		kind := Literal
+		if first := []rune(*n.value)[0]; unicode.IsLetter(first) || first == '_' || first == '$' {
+			kind = Identifier
+		}
+		return Node{Kind: kind, Value: *n.value, Pos: -1}
+	case n.isNegation() || n.isNot():
+		return Node{Kind: Unary, Op: *n.value, Children: []Node{n.right.Node()}, Pos: -1}
+	}
+	var left Node
+	if n.left != nil {
+		left = n.left.Node()
+	}
+	return Node{Kind: Binary, Op: *n.value, Children: []Node{left, n.right.Node()}, Pos: -1}
+}
+
+// token is the text of the node's own token.
+func (n Node) token() string {
+	switch n.Kind {
+	case Unary, Binary:
+		return n.Op
+	case Assign:
+		return string(ASSIGN)
+	case Call:
+		return n.Name
+	}
+	return n.Value
+}
+
+// locate sets the positions by finding the tokens in input in the order they were
+// written, cursor being where the previous one ended.
+func (n *Node) locate(input string, cursor *int) {
+	find := func(token string) int {
+		i := strings.Index(input[*cursor:], token)
+		if i == -1 || token == "" {
+			return -1 // "* *" is lexed as **
+		}
+		*cursor += i + len(token)
+		return *cursor - len(token)
+	}
+	switch n.Kind {
+	case Binary:
+		n.Children[0].locate(input, cursor)
+		n.Pos = find(n.Op)
+		n.Children[1].locate(input, cursor)
+	case Assign:
+		find(n.Name)
+		n.Pos = find(n.token())
+		n.Children[0].locate(input, cursor)
+	default:
+		n.Pos = find(n.token())
+		for i := range n.Children {
+			n.Children[i].locate(input, cursor)
+		}
+	}
+}
+
+// String is the canonical form of the expression, with only the parentheses that left
+// to right evaluation needs: 1 + 2 * 3 is (1 + 2) * 3 and 1 + (2 * 3) stays as is.
+func (n Node) String() string {
+	return n.format(false)
+}
+
+// Parenthesized writes every operation used as an operand in parentheses, showing how
+// the expression groups: 1 + 2 * 3 gives (1 + 2) * 3.
+func (n Node) Parenthesized() string {
+	return n.format(true)
+}
+
+func (n Node) format(full bool) string {
+	switch n.Kind {
+	case Assign:
+		return n.Name + " = " + n.Children[0].format(full)
+	case Call:
+		args := make([]string, len(n.Children))
+		for i, arg := range n.Children {
+			args[i] = arg.format(full)
+		}
+		return n.Name + "(" + strings.Join(args, ", ") + ")"
+	case Unary:
+		operand := n.Children[0]
+		if operand.Kind == Binary || operand.Kind == Assign || operand.Kind == Unary && operand.Op == string(SUB) {
+			return n.Op + "(" + operand.format(full) + ")"
+		}
+		return n.Op + operand.format(full)
+	case Binary:
+		left, right := n.Children[0], n.Children[1]
+		l, r := left.format(full), right.format(full)
+		if full && left.Kind == Binary {
+			l = "(" + l + ")"
+		}
+		if right.Kind == Binary || right.Kind == Assign || right.Kind == Unary && right.Op == string(SUB) {
+			r = "(" + r + ")"
+		}
+		return l + " " + n.Op + " " + r
+	}
+	return n.Value
+}
@@ -0,0 +1,26 @@
+package calculator
+
+import "math/bits"
+
+func init() {
+	register(
+		Function{
+			Name: "popcount", Params: []string{"x"}, Help: "number of bits set in x",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.OnesCount64(uint64(args[0]))), nil //nolint:gosec // reinterpreting bits
+			},
+		},
+		Function{
+			Name: "clz", Params: []string{"x"}, Help: "number of leading zero bits of x, 64 for 0",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.LeadingZeros64(uint64(args[0]))), nil //nolint:gosec // reinterpreting bits
+			},
+		},
+		Function{
+			Name: "ctz", Params: []string{"x"}, Help: "number of trailing zero bits of x, 64 for 0",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.TrailingZeros64(uint64(args[0]))), nil //nolint:gosec // reinterpreting bits
+			},
+		},
+	)
+}
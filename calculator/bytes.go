@@ -0,0 +1,52 @@
+package calculator
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// The le and be functions follow the C htole/htobe conventions of a little-endian host:
+// le keeps the low bytes as they are, be swaps them.
+func init() {
+	register(
+		Function{
+			Name: "le16", Params: []string{"x"}, Help: "low 16 bits of x in little-endian order",
+			Int: func(args []int64) (int64, error) { return args[0] & 0xffff, nil },
+		},
+		Function{
+			Name: "le32", Params: []string{"x"}, Help: "low 32 bits of x in little-endian order",
+			Int: func(args []int64) (int64, error) { return args[0] & 0xffffffff, nil },
+		},
+		Function{
+			Name: "le64", Params: []string{"x"}, Help: "x in little-endian order",
+			Int: func(args []int64) (int64, error) { return args[0], nil },
+		},
+		Function{
+			Name: "be16", Params: []string{"x"}, Help: "low 16 bits of x with their bytes swapped",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.ReverseBytes16(uint16(args[0]))), nil //nolint:gosec // low bits are meant
+			},
+		},
+		Function{
+			Name: "be32", Params: []string{"x"}, Help: "low 32 bits of x with their bytes swapped",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.ReverseBytes32(uint32(args[0]))), nil //nolint:gosec // low bits are meant
+			},
+		},
+		Function{
+			Name: "be64", Params: []string{"x"}, Help: "x with its 8 bytes swapped",
+			Int: func(args []int64) (int64, error) {
+				return int64(bits.ReverseBytes64(uint64(args[0]))), nil //nolint:gosec // reinterpreting bits
+			},
+		},
+		Function{
+			Name: "byte", Params: []string{"x", "n"}, Help: "byte n of x, 0 being the least significant",
+			Int: func(args []int64) (int64, error) {
+				if args[1] < 0 || args[1] > 7 {
+					return 0, errors.New("byte index must be between 0 and 7")
+				}
+				return args[0] >> (8 * args[1]) & 0xff, nil
+			},
+		},
+	)
+}
@@ -1,21 +1,46 @@
 package calculator
 
+import (
+	"errors"
+	"math/big"
+)
+
 type CalcNode struct {
 	left       *CalcNode
 	right      *CalcNode
 	value      *string
 	assignment *assignment
+	call       *call
 }
 
 type State struct {
 	Variables map[string]int64
 	Ans       int64
 	Err       error
+	Mode      Mode
+	// Real is the exact result of the last evaluation in FloatMode or RationalMode
+	// (Ans then holds it truncated), nil in IntegerMode.
+	Real *big.Rat
+	// Reals holds the variables assigned in FloatMode or RationalMode, Variables
+	// gets their truncated value.
+	Reals map[string]*big.Rat
+	// BigEndianStrings packs string literals with their first byte most significant,
+	// by default it is the least significant one as in little-endian memory.
+	BigEndianStrings bool
+	// Width wraps the results of Exec and Evaluate and the values assigned to variables
+	// to that many bits (0 meaning 64), sign extending them unless Unsigned is set. The
+	// operators in between still work on 64 bits.
+	Width    int
+	Unsigned bool
+	// History lists the successful Exec calls, see Result.
+	History []Result
+	lastID  int
 }
 
 func NewState() *State {
 	return &State{
 		Variables: make(map[string]int64),
+		Reals:     make(map[string]*big.Rat),
 	}
 }
 
@@ -70,13 +95,22 @@ func (s *State) Exec(input string) error {
 		s.Err = err
 		return err
 	}
+	if s.Mode != IntegerMode {
+		if err := s.execReal(node); err != nil {
+			return err
+		}
+		s.record(input)
+		return nil
+	}
 	value, err := s.Eval(node)
 	s.Err = err
 	if err != nil {
 		return err
 	}
 
-	s.Ans = value
+	s.Ans = s.Wrap(value)
+	s.Real = nil
+	s.record(input)
 	return nil
 }
 
@@ -84,3 +118,73 @@ type assignment struct {
 	name  string
 	right CalcNode
 }
+
+type call struct {
+	name string
+	args []CalcNode
+}
+
+// Evaluate returns the value of input like Exec but leaves Ans, Real, Err and History
+// alone, for expressions that are evaluated again and again such as the ones the UI
+// keeps on screen. It refuses assignments, which would run again on every evaluation.
+func (s *State) Evaluate(input string) (int64, error) {
+	node, err := s.parseChecked(input)
+	if err != nil {
+		return 0, err
+	}
+	if node.assignment != nil {
+		return 0, errors.New("can't assign " + node.assignment.name + " here")
+	}
+	return s.evaluate(node)
+}
+
+// parseChecked parses input like AST does, refusing the operators left without operands
+// that Eval can't walk, as in "~".
+func (s *State) parseChecked(input string) (CalcNode, error) {
+	tokens, err := s.Tokenize(input)
+	if err != nil {
+		return CalcNode{}, err
+	}
+	node, err := s.Parse(tokens)
+	if err != nil {
+		return CalcNode{}, err
+	}
+	return node, node.Node().validate()
+}
+
+func (s *State) evaluate(node CalcNode) (int64, error) {
+	if s.Mode == IntegerMode {
+		value, err := s.Eval(node)
+		return s.Wrap(value), err
+	}
+	ans, exact, lastErr := s.Ans, s.Real, s.Err
+	defer func() { s.Ans, s.Real, s.Err = ans, exact, lastErr }()
+	err := s.execReal(node)
+	return s.Ans, err
+}
+
+// Wrap reduces value to Width bits, sign extended unless Unsigned.
+func (s *State) Wrap(value int64) int64 {
+	if s.Width <= 0 || s.Width >= 64 {
+		return value
+	}
+	shift := 64 - s.Width
+	if s.Unsigned {
+		return int64(uint64(value) << shift >> shift) //nolint:gosec // bit pattern
+	}
+	return value << shift >> shift
+}
+
+// Assign evaluates input and stores it in the variable name like "name = input" would,
+// without touching Ans or History.
+func (s *State) Assign(name, input string) error {
+	if !isIdentifier(name) {
+		return errors.New("invalid variable name " + name)
+	}
+	node, err := s.parseChecked(input)
+	if err != nil {
+		return err
+	}
+	_, err = s.evaluate(CalcNode{assignment: &assignment{name: name, right: node}})
+	return err
+}
@@ -0,0 +1,261 @@
+package calculator
+
+import (
+	"errors"
+	"slices"
+)
+
+// Program is an integer expression compiled into a tree of closures, with its variables
+// resolved to slots: running it doesn't parse, look up names or allocate. The builtins
+// called with variable arguments share a buffer, so a Program must not be run
+// concurrently, compile one per goroutine instead.
+type Program struct {
+	// Vars are the variables of the expression, Run takes their values in this order.
+	Vars []string
+	run  func(vars []int64) (int64, error)
+	wrap State // the Width and Unsigned of the State the program was compiled in
+}
+
+type compiled func(vars []int64) (int64, error)
+
+var errMissingValues = errors.New("fewer values than variables")
+
+// Compile prepares expr for evaluating it many times with integer semantics. References
+// to results such as $3 or ans are resolved now, the other identifiers are variables
+// whose values are given to Run. Sub-expressions without variables are computed once.
+func (s *State) Compile(expr string) (Program, error) {
+	tokens, err := s.Tokenize(expr)
+	if err != nil {
+		return Program{}, err
+	}
+	node, err := s.Parse(tokens)
+	if err != nil {
+		return Program{}, err
+	}
+	p := Program{wrap: State{Width: s.Width, Unsigned: s.Unsigned}}
+	p.run, err = s.compile(node, &p.Vars)
+	return p, err
+}
+
+// Run evaluates the program with vars holding the values of p.Vars.
+func (p Program) Run(vars []int64) (int64, error) {
+	if len(vars) < len(p.Vars) {
+		return 0, errMissingValues
+	}
+	value, err := p.run(vars)
+	return p.wrap.Wrap(value), err
+}
+
+// Values returns the values the variables of p have in s, for Run.
+func (p Program) Values(s *State) []int64 {
+	values := make([]int64, len(p.Vars))
+	for i, name := range p.Vars {
+		values[i] = s.Variables[name]
+	}
+	return values
+}
+
+func (s *State) compile(node CalcNode, vars *[]string) (compiled, error) { //nolint:gocyclo // one case per node kind
+	switch {
+	case node.assignment != nil:
+		return nil, errors.New("programs can't assign " + node.assignment.name)
+	case node.call != nil:
+		return s.compileCall(node, vars)
+	case node.value == nil:
+		return nil, errors.New("bad value")
+	}
+	if !node.hasVariables(s) {
+		value, err := s.Eval(node)
+		if err != nil {
+			return nil, err
+		}
+		return func([]int64) (int64, error) { return value, nil }, nil
+	}
+	op := *node.value
+	if node.right == nil && node.left == nil {
+		slot := slices.Index(*vars, op)
+		if slot == -1 {
+			slot = len(*vars)
+			*vars = append(*vars, op)
+		}
+		return func(vars []int64) (int64, error) { return vars[slot], nil }, nil
+	}
+	if node.right == nil {
+		return nil, errors.New("invalid operator")
+	}
+	if node.isNegation() || node.isNot() {
+		operand, err := s.compile(*node.right, vars)
+		if err != nil {
+			return nil, err
+		}
+		if node.isNot() {
+			return func(vars []int64) (int64, error) {
+				r, err := operand(vars)
+				return ^r, err
+			}, nil
+		}
+		return func(vars []int64) (int64, error) {
+			r, err := operand(vars)
+			return -r, err
+		}, nil
+	}
+	if node.left == nil {
+		return nil, errors.New("invalid operator")
+	}
+	left, err := s.compile(*node.left, vars) // first so that Vars are in the order they are written
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.compile(*node.right, vars)
+	if err != nil {
+		return nil, err
+	}
+	return compileOperator(op, left, right)
+}
+
+// compileOperator picks the closure of op once instead of switching on it at every run,
+// it matches applyInt.
+func compileOperator(op string, left, right compiled) (compiled, error) { //nolint:funlen,gocyclo // one case per operator
+	operands := func(vars []int64) (int64, int64, error) {
+		l, err := left(vars)
+		if err != nil {
+			return 0, 0, err
+		}
+		r, err := right(vars)
+		return l, r, err
+	}
+	switch op {
+	case "+":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l + r, err
+		}, nil
+	case "-":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l - r, err
+		}, nil
+	case "*":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l * r, err
+		}, nil
+	case "/":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			if err == nil && r == 0 {
+				return 0, errDivisionByZero
+			}
+			if err != nil {
+				return 0, err
+			}
+			return l / r, nil
+		}, nil
+	case "%":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			if err == nil && r == 0 {
+				return 0, errDivisionByZero
+			}
+			if err != nil {
+				return 0, err
+			}
+			return l % r, nil
+		}, nil
+	case "&":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l & r, err
+		}, nil
+	case "|":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l | r, err
+		}, nil
+	case "^":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return l ^ r, err
+		}, nil
+	case "**":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			return intPow(l, r), err
+		}, nil
+	case "<<":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			if err == nil && r < 0 {
+				return 0, errNegativeShift
+			}
+			return l << max(r, 0), err
+		}, nil
+	case ">>":
+		return func(vars []int64) (int64, error) {
+			l, r, err := operands(vars)
+			if err == nil && r < 0 {
+				return 0, errNegativeShift
+			}
+			return l >> max(r, 0), err
+		}, nil
+	}
+	return nil, errors.New("invalid operator " + op)
+}
+
+// compileCall calls the integer implementation of the function, those without one can
+// only be called with constant arguments.
+func (s *State) compileCall(node CalcNode, vars *[]string) (compiled, error) {
+	f, err := lookup(*node.call)
+	if err != nil {
+		return nil, err
+	}
+	if !node.hasVariables(s) {
+		value, err := s.call(*node.call)
+		if err != nil {
+			return nil, err
+		}
+		return func([]int64) (int64, error) { return value, nil }, nil
+	}
+	if f.Int == nil {
+		return nil, errors.New(f.Signature() + " needs real arithmetic, programs can only call it with constants")
+	}
+	args := make([]compiled, len(node.call.args))
+	for i, arg := range node.call.args {
+		if args[i], err = s.compile(arg, vars); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]int64, len(args))
+	return func(vars []int64) (int64, error) {
+		for i, arg := range args {
+			value, err := arg(vars)
+			if err != nil {
+				return 0, err
+			}
+			values[i] = value
+		}
+		return f.Int(values)
+	}, nil
+}
+
+// hasVariables reports whether n reads a variable, references to results being constants.
+func (n CalcNode) hasVariables(s *State) bool {
+	switch {
+	case n.assignment != nil:
+		return true
+	case n.call != nil:
+		return slices.ContainsFunc(n.call.args, func(arg CalcNode) bool { return arg.hasVariables(s) })
+	case n.value == nil:
+		return false
+	case n.right == nil && n.left == nil:
+		value := *n.value
+		if _, err := s.parseLiteral(value); err == nil {
+			return false
+		}
+		if _, ok, _ := s.reference(value); ok {
+			return false
+		}
+		return isIdentifier(value)
+	}
+	return n.left != nil && n.left.hasVariables(s) || n.right != nil && n.right.hasVariables(s)
+}
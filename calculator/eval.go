@@ -13,13 +13,18 @@ func (s *State) Eval(curNode CalcNode) (int64, error) { //nolint:funlen,gocyclo
 		if err != nil {
 			return -1, err
 		}
-		s.Variables[curNode.assignment.name] = num
+		// operators work on 64 bits, but variables hold what Width keeps of them
+		num = s.Wrap(num)
+		s.setVariable(curNode.assignment.name, num)
 		return num, nil
 	}
+	if curNode.call != nil {
+		return s.call(*curNode.call)
+	}
 	if curNode.value == nil {
 		return -1, errors.New("bad value")
 	}
-	if *curNode.value == "-" && (curNode.left == nil || curNode.left.value == nil) {
+	if curNode.isNegation() {
 		num, err := s.Eval(*curNode.right)
 		if err != nil {
 			return -1, err
@@ -27,38 +32,18 @@ func (s *State) Eval(curNode CalcNode) (int64, error) { //nolint:funlen,gocyclo
 		return -1 * num, nil
 	}
 	if slices.Contains(Length1operatorsInfix, Operator((*curNode.value)[0])) {
+		if curNode.left == nil || curNode.right == nil {
+			return 0, errors.New("invalid operator")
+		}
 		l, err := s.Eval(*curNode.left)
 		if err != nil {
 			return 0, err
 		}
-		if curNode.right == nil {
-			return 0, errors.New("invalid operator")
-		}
-
 		r, err := s.Eval(*curNode.right)
 		if err != nil {
 			return 0, err
 		}
-		switch *curNode.value {
-		case "+":
-			return l + r, nil
-		case "-":
-			return l - r, nil
-		case "*":
-			return l * r, nil
-		case "/":
-			return l / r, nil
-		case "&":
-			return l & r, nil
-		case "^":
-			return l ^ r, nil
-		case "|":
-			return l | r, nil
-		case "%":
-			return l % r, nil
-		default:
-			return -1, errors.New("invalid operator")
-		}
+		return applyInt(*curNode.value, l, r)
 	}
 	if slices.Contains(Length1operatorsPrefix, Operator((*curNode.value)[0])) {
 		num, err := s.Eval(*curNode.right)
@@ -81,24 +66,128 @@ func (s *State) Eval(curNode CalcNode) (int64, error) { //nolint:funlen,gocyclo
 		if err != nil {
 			return 0, err
 		}
-		switch *curNode.value {
-		case "<<":
-			return l << r, nil
-		case ">>":
-			return l >> r, nil
-		case "**":
-			f := math.Pow(float64(l), float64(r))
-			return int64(f), nil
-		default:
-			return -1, errors.New("bad double rune operator")
-		}
+		return applyInt(*curNode.value, l, r)
 	}
-	num, err := strconv.ParseInt(*curNode.value, 10, 64)
+	num, err := s.parseLiteral(*curNode.value)
 	if err != nil {
-		if *curNode.value == "_ans_" {
-			return s.Ans, nil
+		if quote := (*curNode.value)[0]; quote == '\'' || quote == '"' {
+			return 0, err // the character or string literal's own error
+		}
+		if result, ok, err := s.reference(*curNode.value); ok {
+			return result.Value, err
+		}
+		if !isIdentifier(*curNode.value) {
+			return realLiteralToInt(*curNode.value)
 		}
 		return s.Variables[*curNode.value], nil
 	}
 	return num, nil
 }
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errNegativeShift  = errors.New("negative shift count")
+)
+
+// applyInt applies a binary operator to integers, shared by every evaluation mode
+// for the operators that only make sense on integers.
+func applyInt(op string, l, r int64) (int64, error) { //nolint:gocyclo // one case per operator
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return 0, errDivisionByZero
+		}
+		return l / r, nil
+	case "&":
+		return l & r, nil
+	case "^":
+		return l ^ r, nil
+	case "|":
+		return l | r, nil
+	case "%":
+		if r == 0 {
+			return 0, errDivisionByZero
+		}
+		return l % r, nil
+	case "**":
+		return intPow(l, r), nil
+	case "<<":
+		if r < 0 {
+			return 0, errNegativeShift
+		}
+		return l << r, nil
+	case ">>":
+		if r < 0 {
+			return 0, errNegativeShift
+		}
+		return l >> r, nil
+	default:
+		return -1, errors.New("invalid operator")
+	}
+}
+
+func (n CalcNode) isNegation() bool {
+	return *n.value == string(SUB) && (n.left == nil || n.left.value == nil && n.left.call == nil && n.left.assignment == nil)
+}
+
+// intPow raises base to exp by squaring, wrapping around like the other integer operators.
+func intPow(base, exp int64) int64 {
+	if exp < 0 {
+		switch base {
+		case 1:
+			return 1
+		case -1:
+			return 1 - 2*(-exp%2)
+		default:
+			return 0
+		}
+	}
+	result := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+	return result
+}
+
+// realLiteralToInt accepts real literals such as 1e3 that happen to be integers and
+// refuses the others, as integer mode would otherwise silently drop their fraction.
+func realLiteralToInt(literal string) (int64, error) {
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, errors.New("invalid number " + literal)
+	}
+	if f != math.Trunc(f) {
+		return 0, errors.New(literal + " is not an integer, use round(" + literal + ") or a real mode")
+	}
+	return truncateFloat(f)
+}
+
+// parseLiteral reads decimal, 0x, 0o and 0b prefixed integers as well as character and
+// string literals. Values that only fit in 64 unsigned bits (eg 0xffffffffffffffff) wrap
+// around into the int64 range.
+func (s *State) parseLiteral(value string) (int64, error) {
+	switch value[0] {
+	case '\'':
+		return charLiteral(value)
+	case '"':
+		return s.stringLiteral(value)
+	}
+	num, err := strconv.ParseInt(value, 0, 64)
+	if err == nil {
+		return num, nil
+	}
+	unsigned, uerr := strconv.ParseUint(value, 0, 64)
+	if uerr != nil {
+		return 0, err
+	}
+	return int64(unsigned), nil //nolint:gosec // wrapping is what we want for bit patterns
+}
@@ -0,0 +1,89 @@
+package calculator
+
+import (
+	"maps"
+	"math/big"
+)
+
+// Step is a sub-expression of an explained input with the value it evaluates to.
+type Step struct {
+	Expr   string
+	Depth  int // 0 for the whole input
+	Parent int // index of the step using this one, -1 for the whole input
+	Value  int64
+	Real   *big.Rat // exact value in the float and rational modes
+	Err    error
+}
+
+// Explain evaluates input one sub-expression at a time and returns the steps in
+// evaluation order, the whole input being the last one. It leaves s untouched:
+// assignments only happen in a copy of the variables.
+func (s *State) Explain(input string) ([]Step, error) {
+	node, err := s.parseChecked(input)
+	if err != nil {
+		return nil, err
+	}
+	scratch := *s
+	scratch.Variables, scratch.Reals = maps.Clone(s.Variables), maps.Clone(s.Reals)
+	var steps []Step
+	scratch.explain(node, 0, &steps)
+	return steps, nil
+}
+
+// explain appends the steps of node's operands then node's own, returning its index.
+func (s *State) explain(node CalcNode, depth int, steps *[]Step) int {
+	var children []int
+	for _, child := range node.operands() {
+		if node.call != nil && s.Mode == IntegerMode && s.readsReals(node, child) {
+			s.Mode = FloatMode
+			children = append(children, s.explain(child, depth+1, steps))
+			s.Mode = IntegerMode
+			continue
+		}
+		children = append(children, s.explain(child, depth+1, steps))
+	}
+	step := Step{Expr: node.Node().Parenthesized(), Depth: depth, Parent: -1}
+	if s.Mode == IntegerMode {
+		step.Value, step.Err = s.Eval(node)
+		if depth == 0 {
+			step.Value = s.Wrap(step.Value)
+		}
+	} else {
+		step.Err = s.execReal(node)
+		step.Value, step.Real = s.Ans, s.Real
+	}
+	*steps = append(*steps, step)
+	index := len(*steps) - 1
+	for _, child := range children {
+		(*steps)[child].Parent = index
+	}
+	return index
+}
+
+// readsReals reports whether the call node reads its argument arg as a real, like f32(1.5)
+// does in integer mode.
+func (s *State) readsReals(node, arg CalcNode) bool {
+	if _, err := s.Eval(arg); err == nil {
+		return false
+	}
+	_, err := s.Eval(node)
+	return err == nil
+}
+
+// operands are the sub-expressions of n in the order they are evaluated.
+func (n CalcNode) operands() []CalcNode {
+	switch {
+	case n.assignment != nil:
+		return []CalcNode{n.assignment.right}
+	case n.call != nil:
+		return n.call.args
+	}
+	var operands []CalcNode
+	if n.left != nil && (n.left.value != nil || n.left.call != nil || n.left.assignment != nil) {
+		operands = append(operands, *n.left)
+	}
+	if n.right != nil {
+		operands = append(operands, *n.right)
+	}
+	return operands
+}
@@ -0,0 +1,217 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// QFormat is a Qm.n fixed-point format: m integer bits and n fractional bits, plus a
+// sign bit unless Unsigned (UQm.n). Q15 is Q0.15 held in 16 bits.
+type QFormat struct {
+	M, N     int
+	Unsigned bool
+}
+
+// ParseQFormat reads formats written as Q15, Q1.14 or UQ8.8.
+func ParseQFormat(name string) (QFormat, error) {
+	var q QFormat
+	upper := strings.ToUpper(name)
+	if strings.HasPrefix(upper, "U") {
+		q.Unsigned = true
+		upper = upper[1:]
+	}
+	rest, ok := strings.CutPrefix(upper, "Q")
+	if !ok {
+		return q, fmt.Errorf("invalid Q format %q", name)
+	}
+	integer, fraction, hasDot := strings.Cut(rest, ".")
+	var err error
+	if !hasDot {
+		integer, fraction = "0", rest
+	}
+	if q.M, err = strconv.Atoi(integer); err != nil {
+		return q, fmt.Errorf("invalid Q format %q", name)
+	}
+	if q.N, err = strconv.Atoi(fraction); err != nil {
+		return q, fmt.Errorf("invalid Q format %q", name)
+	}
+	return q, q.validate()
+}
+
+func (q QFormat) validate() error {
+	if q.M < 0 || q.N < 0 || q.Width() < 1 || q.Width() > 64 {
+		return fmt.Errorf("%v needs between 1 and 64 bits", q)
+	}
+	return nil
+}
+
+// Width is the number of bits of a value in this format.
+func (q QFormat) Width() int {
+	if q.Unsigned {
+		return q.M + q.N
+	}
+	return q.M + q.N + 1
+}
+
+func (q QFormat) String() string {
+	name := "Q" + strconv.Itoa(q.M) + "." + strconv.Itoa(q.N)
+	if q.Unsigned {
+		return "U" + name
+	}
+	return name
+}
+
+// Value interprets the low Width bits of bits in this format.
+func (q QFormat) Value(bits int64) *big.Rat {
+	raw := new(big.Int).SetUint64(uint64(bits)) //nolint:gosec // reinterpreting bits
+	raw.And(raw, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(q.Width())), big.NewInt(1)))
+	if !q.Unsigned && raw.Bit(q.Width()-1) == 1 {
+		raw.Sub(raw, new(big.Int).Lsh(big.NewInt(1), uint(q.Width())))
+	}
+	return new(big.Rat).SetFrac(raw, new(big.Int).Lsh(big.NewInt(1), uint(q.N)))
+}
+
+// limits returns the smallest and largest raw integers of the format.
+func (q QFormat) limits() (low, high *big.Int) {
+	if q.Unsigned {
+		return big.NewInt(0), new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(q.Width())), big.NewInt(1))
+	}
+	high = new(big.Int).Lsh(big.NewInt(1), uint(q.Width()-1))
+	return new(big.Int).Neg(high), high.Sub(high, big.NewInt(1))
+}
+
+func (q QFormat) saturate(raw *big.Int) *big.Int {
+	low, high := q.limits()
+	switch {
+	case raw.Cmp(low) < 0:
+		return low
+	case raw.Cmp(high) > 0:
+		return high
+	}
+	return raw
+}
+
+// FromReal converts x to the raw integer of the format, rounding to nearest and saturating.
+func (q QFormat) FromReal(x *big.Rat) int64 {
+	scaled := new(big.Rat).Mul(x, new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(q.N))))
+	return truncateRat(new(big.Rat).SetInt(q.saturate(ratRound(scaled).Num())))
+}
+
+// Mul multiplies two raw values of the format with round to nearest and saturation,
+// like DSP fractional multiply instructions.
+func (q QFormat) Mul(a, b int64) int64 {
+	product := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	if q.N > 0 {
+		product.Add(product, new(big.Int).Lsh(big.NewInt(1), uint(q.N-1)))
+		product.Rsh(product, uint(q.N))
+	}
+	return truncateRat(new(big.Rat).SetInt(q.saturate(product)))
+}
+
+func init() {
+	register(
+		Function{
+			Name: "q", Params: []string{"x", "m", "n"}, Help: "x as a signed Qm.n raw value, rounded and saturated",
+			Rat: qConversion(false),
+		},
+		Function{
+			Name: "uq", Params: []string{"x", "m", "n"}, Help: "x as an unsigned UQm.n raw value, rounded and saturated",
+			Rat: qConversion(true),
+		},
+		Function{
+			Name: "q15", Params: []string{"x"}, Help: "x as a Q15 raw value",
+			Rat: func(args []*big.Rat) (*big.Rat, error) {
+				return new(big.Rat).SetInt64(QFormat{N: 15}.FromReal(args[0])), nil
+			},
+		},
+		Function{
+			Name: "q31", Params: []string{"x"}, Help: "x as a Q31 raw value",
+			Rat: func(args []*big.Rat) (*big.Rat, error) {
+				return new(big.Rat).SetInt64(QFormat{N: 31}.FromReal(args[0])), nil
+			},
+		},
+		Function{
+			Name: "fromq", Params: []string{"x", "n"}, Help: "value of the raw fixed-point x with n fractional bits",
+			Rat: func(args []*big.Rat) (*big.Rat, error) {
+				n, err := smallInt(args[1], 0, 64)
+				if err != nil {
+					return nil, err
+				}
+				scale := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(n)))
+				return new(big.Rat).Quo(args[0], scale), nil
+			},
+		},
+		Function{
+			Name: "qmul", Params: []string{"a", "b", "m", "n"}, Help: "Qm.n multiply with rounding and saturation",
+			Int: func(args []int64) (int64, error) {
+				q := QFormat{M: int(args[2]), N: int(args[3])}
+				if err := q.validate(); err != nil {
+					return 0, err
+				}
+				return q.Mul(args[0], args[1]), nil
+			},
+		},
+		Function{
+			Name: "q15mul", Params: []string{"a", "b"}, Help: "Q15 multiply with rounding and saturation",
+			Int: func(args []int64) (int64, error) { return QFormat{N: 15}.Mul(args[0], args[1]), nil },
+		},
+		Function{
+			Name: "q31mul", Params: []string{"a", "b"}, Help: "Q31 multiply with rounding and saturation",
+			Int: func(args []int64) (int64, error) { return QFormat{N: 31}.Mul(args[0], args[1]), nil },
+		},
+		Function{
+			Name: "sat", Params: []string{"x", "bits"}, Help: "x clamped to the signed range of bits",
+			Int: saturation(false),
+		},
+		Function{
+			Name: "usat", Params: []string{"x", "bits"}, Help: "x clamped to the unsigned range of bits",
+			Int: saturation(true),
+		},
+	)
+}
+
+func qConversion(unsigned bool) func(args []*big.Rat) (*big.Rat, error) {
+	return func(args []*big.Rat) (*big.Rat, error) {
+		m, err := smallInt(args[1], 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		n, err := smallInt(args[2], 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		q := QFormat{M: m, N: n, Unsigned: unsigned}
+		if err := q.validate(); err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt64(q.FromReal(args[0])), nil
+	}
+}
+
+func saturation(unsigned bool) func(args []int64) (int64, error) {
+	return func(args []int64) (int64, error) {
+		if args[1] < 1 || args[1] > 64 {
+			return 0, errors.New("bits must be between 1 and 64")
+		}
+		q := QFormat{N: int(args[1]) - 1}
+		if unsigned {
+			q = QFormat{N: int(args[1]), Unsigned: true}
+		}
+		return truncateRat(new(big.Rat).SetInt(q.saturate(big.NewInt(args[0])))), nil
+	}
+}
+
+// smallInt converts a function argument that has to be an integer in [low, high].
+func smallInt(r *big.Rat, low, high int) (int, error) {
+	if !r.IsInt() {
+		return 0, errors.New("expected an integer, got " + r.FloatString(6))
+	}
+	n := truncateRat(r)
+	if n < int64(low) || n > int64(high) {
+		return 0, fmt.Errorf("%d is not between %d and %d", n, low, high)
+	}
+	return int(n), nil
+}
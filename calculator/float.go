@@ -0,0 +1,82 @@
+package calculator
+
+import (
+	"math"
+)
+
+func init() {
+	register(
+		Function{
+			Name: "f16", Params: []string{"x"}, Help: "bit pattern of x as an IEEE-754 half precision float",
+			Eval: floatBits(func(f float64) int64 { return int64(Float16Bits(f)) }),
+		},
+		Function{
+			Name: "f32", Params: []string{"x"}, Help: "bit pattern of x as an IEEE-754 single precision float",
+			Eval: floatBits(func(f float64) int64 { return int64(math.Float32bits(float32(f))) }),
+		},
+		Function{
+			Name: "f64", Params: []string{"x"}, Help: "bit pattern of x as an IEEE-754 double precision float",
+			Eval: floatBits(func(f float64) int64 { return int64(math.Float64bits(f)) }), //nolint:gosec // reinterpreting bits
+		},
+	)
+	for _, width := range []string{"16", "32", "64"} {
+		f := Functions["f"+width]
+		f.Name += "bits"
+		register(f)
+	}
+}
+
+func floatBits(bits func(float64) int64) func(s *State, args []CalcNode) (int64, error) {
+	return func(s *State, args []CalcNode) (int64, error) {
+		f, err := s.evalFloat(args[0])
+		if err != nil {
+			return 0, err
+		}
+		return bits(f), nil
+	}
+}
+
+// Float16Bits converts f to the nearest IEEE-754 half precision value, rounding ties to even.
+func Float16Bits(f float64) uint16 {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+	}
+	abs := math.Abs(f)
+	switch {
+	case math.IsNaN(f):
+		return sign | 0x7e00
+	case abs >= 65520: // halfway between the largest half (65504) and the next power of two
+		return sign | 0x7c00
+	case abs < 0x1p-14: // subnormal, multiples of 2^-24
+		return sign | uint16(math.RoundToEven(abs*(1<<24)))
+	}
+	frac, exp := math.Frexp(abs) // abs = frac * 2^exp with frac in [0.5, 1)
+	mantissa := uint16(math.RoundToEven((2*frac - 1) * 1024))
+	biased := uint16(exp - 1 + 15) //nolint:gosec // exp is within the normal half range here
+	if mantissa == 1024 {
+		mantissa = 0
+		biased++
+	}
+	return sign | biased<<10 | mantissa
+}
+
+// Float16Value returns the value of the half precision float with the given bits.
+func Float16Value(bits uint16) float64 {
+	sign := 1.0
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(1024+mantissa, exp-25)
+}
@@ -0,0 +1,138 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Function is a builtin that expressions call as name(args...).
+type Function struct {
+	Name   string
+	Params []string // parameter names, len(Params) is the number of arguments expected
+	Help   string
+	// Eval receives the arguments unevaluated so that functions like f32 can read them as reals.
+	Eval func(s *State, args []CalcNode) (int64, error)
+	// Int computes the function on integers, Eval defaults to it. Compiled programs
+	// can only call functions with variable arguments through Int.
+	Int func(args []int64) (int64, error)
+	// Real computes the function on reals. Functions without Eval evaluate their arguments
+	// as reals in integer mode too and truncate the result.
+	Real func(args []float64) (float64, error)
+	// Rat computes the function exactly, otherwise RationalMode goes through Real.
+	Rat func(args []*big.Rat) (*big.Rat, error)
+}
+
+// Functions holds every builtin by name.
+var Functions = map[string]Function{}
+
+func register(functions ...Function) {
+	for _, f := range functions {
+		if f.Eval == nil && f.Int != nil {
+			f.Eval = intFunction(f.Int)
+		}
+		Functions[f.Name] = f
+	}
+}
+
+// Signature returns how the function is called, eg "f32(x)".
+func (f Function) Signature() string {
+	return f.Name + "(" + strings.Join(f.Params, ", ") + ")"
+}
+
+// intFunction adapts a function of evaluated integer arguments.
+func intFunction(fn func(args []int64) (int64, error)) func(s *State, args []CalcNode) (int64, error) {
+	return func(s *State, args []CalcNode) (int64, error) {
+		values := make([]int64, len(args))
+		for i, arg := range args {
+			value, err := s.Eval(arg)
+			if err != nil {
+				return 0, err
+			}
+			values[i] = value
+		}
+		return fn(values)
+	}
+}
+
+func lookup(c call) (Function, error) {
+	f, ok := Functions[c.name]
+	if !ok {
+		return f, errors.New("unknown function " + c.name)
+	}
+	if len(c.args) != len(f.Params) {
+		return f, fmt.Errorf("%s expects %d arguments, got %d", f.Signature(), len(f.Params), len(c.args))
+	}
+	return f, nil
+}
+
+func (s *State) call(c call) (int64, error) {
+	f, err := lookup(c)
+	if err != nil {
+		return 0, err
+	}
+	if f.Eval != nil {
+		return f.Eval(s, c.args)
+	}
+	r, err := s.callRat(c)
+	if err != nil {
+		return 0, err
+	}
+	return truncateRat(r), nil
+}
+
+func (s *State) callFloat(c call) (float64, error) {
+	f, err := lookup(c)
+	if err != nil {
+		return 0, err
+	}
+	if f.Real == nil {
+		if f.Rat != nil {
+			r, err := s.callRat(c)
+			if err != nil {
+				return 0, err
+			}
+			result, _ := r.Float64()
+			return result, nil
+		}
+		num, err := s.call(c)
+		return float64(num), err
+	}
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		if args[i], err = s.evalFloat(arg); err != nil {
+			return 0, err
+		}
+	}
+	return f.Real(args)
+}
+
+func (s *State) callRat(c call) (*big.Rat, error) {
+	f, err := lookup(c)
+	if err != nil {
+		return nil, err
+	}
+	if f.Rat == nil && f.Real == nil {
+		num, err := s.call(c)
+		return new(big.Rat).SetInt64(num), err
+	}
+	args := make([]*big.Rat, len(c.args))
+	for i, arg := range c.args {
+		if args[i], err = s.evalRat(arg); err != nil {
+			return nil, err
+		}
+	}
+	if f.Rat != nil {
+		return f.Rat(args)
+	}
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		floats[i], _ = arg.Float64()
+	}
+	result, err := f.Real(floats)
+	if err != nil {
+		return nil, err
+	}
+	return ratFromFloat(result)
+}
@@ -0,0 +1,99 @@
+package calculator
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Result is a successful Exec. Results are numbered from 1 so that expressions can
+// refer to them as $n or _n, numbers are never reused.
+type Result struct {
+	ID    int
+	Input string
+	Value int64
+	Real  *big.Rat // exact value in FloatMode or RationalMode, nil in IntegerMode
+}
+
+func (s *State) record(input string) {
+	s.lastID++
+	s.History = append(s.History, Result{ID: s.lastID, Input: input, Value: s.Ans, Real: s.Real})
+}
+
+// Result returns the result numbered id.
+func (s *State) Result(id int) (Result, bool) {
+	for _, result := range s.History {
+		if result.ID == id {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// DeleteResult forgets the result numbered id, the others keep their number.
+func (s *State) DeleteResult(id int) bool {
+	for i, result := range s.History {
+		if result.ID == id {
+			s.History = append(s.History[:i], s.History[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Recall makes the result numbered id the current answer again.
+func (s *State) Recall(id int) bool {
+	result, ok := s.Result(id)
+	if ok {
+		s.Ans, s.Real, s.Err = result.Value, result.Real, nil
+	}
+	return ok
+}
+
+// reference resolves references to previous results, unless a variable of the same name
+// was assigned:
+//
+//	ans, _ans_   the current answer, Ans (or Real)
+//	ans1, ans2…  the last result, the one before it…
+//	$$, $-2…     the same, relative to the end of History
+//	$3, _3       the result numbered 3
+//
+// ok is false when token is not a reference at all.
+func (s *State) reference(token string) (result Result, ok bool, err error) {
+	if _, isVariable := s.Variables[token]; isVariable {
+		return result, false, nil
+	}
+	var digits string
+	relative := false
+	switch {
+	case token == "ans" || token == "_ans_":
+		return Result{Value: s.Ans, Real: s.Real}, true, nil
+	case token == "$$":
+		digits, relative = "1", true
+	case strings.HasPrefix(token, "ans"):
+		digits, relative = token[len("ans"):], true
+	case strings.HasPrefix(token, "$-"):
+		digits, relative = token[len("$-"):], true
+	case strings.HasPrefix(token, "$"), strings.HasPrefix(token, "_"):
+		digits = token[1:]
+	}
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return result, false, nil
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return result, true, err
+	}
+	if !relative {
+		result, found := s.Result(n)
+		if !found {
+			return result, true, fmt.Errorf("no result %s", token)
+		}
+		return result, true, nil
+	}
+	if n == 0 || n > len(s.History) {
+		return result, true, fmt.Errorf("no result %s, there are %d", token, len(s.History))
+	}
+	return s.History[len(s.History)-n], true, nil
+}
@@ -0,0 +1,338 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SkippedConstant is a constant found by Import that could not be evaluated.
+type SkippedConstant struct {
+	Name string
+	Expr string
+	Err  error
+}
+
+// ImportResult lists the constants Import loaded into the variables and the ones it had to skip.
+type ImportResult struct {
+	Loaded  []string
+	Skipped []SkippedConstant
+}
+
+type importedConstant struct {
+	name   string
+	source string // as written in the file
+	expr   string // fully parenthesised calculator expression
+	err    error
+}
+
+// Import loads the integer constants of a C header (#define and enum) or, for .go files,
+// of the Go const declarations into s.Variables.
+func (s *State) Import(path string) (ImportResult, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if filepath.Ext(path) == ".go" {
+		return s.ImportGo(path, src)
+	}
+	return s.ImportC(string(src)), nil
+}
+
+var (
+	cBlockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cLineComment  = regexp.MustCompile(`//[^\n]*`)
+	cDefine       = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*define[ \t]+([A-Za-z_]\w*)(?:[ \t]+(.*?))?[ \t]*$`)
+	cEnum         = regexp.MustCompile(`\benum\b[^{;]*\{([^}]*)\}`)
+	cIntSuffix    = regexp.MustCompile(`\b(0[xX][0-9a-fA-F]+|\d+)[uUlL]+\b`)
+	cCast         = regexp.MustCompile(`\(\s*(?:(?:unsigned|signed|const|volatile)\s+)*` +
+		`(?:char|short|int|long|u?int(?:8|16|32|64|ptr)_t|s?size_t|[us](?:8|16|32|64))` +
+		`(?:\s+(?:int|long))*\s*\)`)
+)
+
+// cPrecedence follows C: the calculator itself evaluates left to right, so expressions
+// from headers are fully parenthesised before evaluation.
+var cPrecedence = map[string]int{
+	"*": 10, "/": 10, "%": 10,
+	"+": 9, "-": 9,
+	"<<": 8, ">>": 8,
+	"&": 5,
+	"^": 4,
+	"|": 3,
+}
+
+// ImportC loads the object-like #define macros and enum constants of a C header.
+func (s *State) ImportC(src string) ImportResult {
+	src = strings.ReplaceAll(src, "\\\r\n", " ")
+	src = strings.ReplaceAll(src, "\\\n", " ")
+	src = cBlockComment.ReplaceAllString(src, " ")
+	src = cLineComment.ReplaceAllString(src, "")
+	var consts []importedConstant
+	for _, match := range cDefine.FindAllStringSubmatch(src, -1) {
+		if match[2] == "" {
+			continue // include guards and feature flags
+		}
+		consts = append(consts, s.cConstant(match[1], match[2]))
+	}
+	for _, match := range cEnum.FindAllStringSubmatch(src, -1) {
+		previous := ""
+		for _, member := range strings.Split(match[1], ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			name, value, explicit := strings.Cut(member, "=")
+			name = strings.TrimSpace(name)
+			switch {
+			case explicit:
+				value = strings.TrimSpace(value)
+			case previous == "":
+				value = "0"
+			default:
+				value = previous + " + 1"
+			}
+			consts = append(consts, s.cConstant(name, value))
+			previous = name
+		}
+	}
+	return s.load(consts)
+}
+
+func (s *State) cConstant(name, source string) importedConstant {
+	constant := importedConstant{name: name, source: source}
+	cleaned := cCast.ReplaceAllString(source, "")
+	cleaned = cIntSuffix.ReplaceAllString(cleaned, "$1")
+	tokens, err := s.Tokenize(cleaned)
+	if err != nil {
+		constant.err = err
+		return constant
+	}
+	p := cExprParser{tokens: tokens}
+	constant.expr, constant.err = p.binary(0)
+	if constant.err == nil && p.pos != len(tokens) {
+		constant.err = fmt.Errorf("unexpected %q", tokens[p.pos])
+	}
+	return constant
+}
+
+type cExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *cExprParser) binary(minPrecedence int) (string, error) {
+	left, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	for p.pos < len(p.tokens) {
+		op := p.tokens[p.pos]
+		precedence, ok := cPrecedence[op]
+		if !ok || precedence < minPrecedence {
+			break
+		}
+		p.pos++
+		right, err := p.binary(precedence + 1)
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + op + right + ")"
+	}
+	return left, nil
+}
+
+func (p *cExprParser) unary() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", errors.New("unexpected end of expression")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token {
+	case "-", "~":
+		operand, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		return "(" + token + operand + ")", nil
+	case "+":
+		return p.unary()
+	case "(":
+		inner, err := p.binary(0)
+		if err != nil {
+			return "", err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos] != ")" {
+			return "", errors.New("missing )")
+		}
+		p.pos++
+		return "(" + inner + ")", nil
+	}
+	if _, isOperator := cPrecedence[token]; isOperator || token == ")" {
+		return "", fmt.Errorf("unexpected %q", token)
+	}
+	return token, nil
+}
+
+// ImportGo loads the integer constants declared in a Go source file, including iota sequences.
+func (s *State) ImportGo(filename string, src []byte) (ImportResult, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var consts []importedConstant
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		var values []ast.Expr
+		for iota, spec := range gen.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			if len(valueSpec.Values) > 0 {
+				values = valueSpec.Values
+			}
+			for i, name := range valueSpec.Names {
+				if name.Name == "_" || i >= len(values) {
+					continue
+				}
+				constant := importedConstant{name: name.Name, source: types.ExprString(values[i])}
+				constant.expr, constant.err = goExpr(values[i], iota)
+				consts = append(consts, constant)
+			}
+		}
+	}
+	return s.load(consts), nil
+}
+
+var goIntegerTypes = []string{
+	"int", "int8", "int16", "int32", "int64",
+	"uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune",
+}
+
+func goExpr(expr ast.Expr, iota int) (string, error) { //nolint:gocyclo // one case per node type
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT && e.Kind != token.CHAR {
+			return "", fmt.Errorf("%s literal is not an integer", strings.ToLower(e.Kind.String()))
+		}
+		return e.Value, nil
+	case *ast.Ident:
+		if e.Name == "iota" {
+			return strconv.Itoa(iota), nil
+		}
+		return e.Name, nil
+	case *ast.ParenExpr:
+		return goExpr(e.X, iota)
+	case *ast.UnaryExpr:
+		operand, err := goExpr(e.X, iota)
+		if err != nil {
+			return "", err
+		}
+		switch e.Op { //nolint:exhaustive // only integer operators are supported
+		case token.ADD:
+			return operand, nil
+		case token.SUB:
+			return "(-" + operand + ")", nil
+		case token.XOR:
+			return "(~" + operand + ")", nil
+		default:
+			return "", fmt.Errorf("unsupported operator %s", e.Op)
+		}
+	case *ast.BinaryExpr:
+		left, err := goExpr(e.X, iota)
+		if err != nil {
+			return "", err
+		}
+		right, err := goExpr(e.Y, iota)
+		if err != nil {
+			return "", err
+		}
+		switch e.Op { //nolint:exhaustive // only integer operators are supported
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+			token.AND, token.OR, token.XOR, token.SHL, token.SHR:
+			return "(" + left + e.Op.String() + right + ")", nil
+		case token.AND_NOT:
+			return "(" + left + "&(~" + right + "))", nil
+		default:
+			return "", fmt.Errorf("unsupported operator %s", e.Op)
+		}
+	case *ast.CallExpr:
+		if fun, ok := e.Fun.(*ast.Ident); ok && len(e.Args) == 1 && slices.Contains(goIntegerTypes, fun.Name) {
+			return goExpr(e.Args[0], iota)
+		}
+	}
+	return "", fmt.Errorf("unsupported expression %s", types.ExprString(expr))
+}
+
+// load evaluates the constants, retrying the ones referring to constants that are
+// defined further down the file until no more progress is made.
+func (s *State) load(consts []importedConstant) ImportResult {
+	var result ImportResult
+	pending := make([]*importedConstant, 0, len(consts))
+	for i := range consts {
+		if consts[i].err == nil {
+			pending = append(pending, &consts[i])
+		}
+	}
+	for progress := true; progress; {
+		progress = false
+		remaining := pending[:0]
+		for _, constant := range pending {
+			value, err := s.evalConstant(constant.expr)
+			if err != nil {
+				constant.err = err
+				remaining = append(remaining, constant)
+				continue
+			}
+			constant.err = nil
+			s.setVariable(constant.name, value)
+			result.Loaded = append(result.Loaded, constant.name)
+			progress = true
+		}
+		pending = remaining
+	}
+	for _, constant := range consts {
+		if constant.err != nil {
+			result.Skipped = append(result.Skipped, SkippedConstant{constant.name, constant.source, constant.err})
+		}
+	}
+	return result
+}
+
+// evalConstant evaluates expr without touching Ans, refusing unknown identifiers
+// instead of treating them as 0 like interactive input does.
+func (s *State) evalConstant(expr string) (int64, error) {
+	tokens, err := s.Tokenize(expr)
+	if err != nil {
+		return 0, err
+	}
+	for _, token := range tokens {
+		if _, isOperator := cPrecedence[token]; isOperator || token == "(" || token == ")" || token == "~" {
+			continue
+		}
+		if isIdentifier(token) {
+			if _, ok := s.Variables[token]; !ok {
+				return 0, fmt.Errorf("undefined identifier %s", token)
+			}
+		} else if _, err := s.parseLiteral(token); err != nil || token[0] == '"' {
+			return 0, fmt.Errorf("invalid integer %s", token)
+		}
+	}
+	node, err := s.Parse(tokens)
+	if err != nil {
+		return 0, err
+	}
+	return s.Eval(node)
+}
@@ -7,19 +7,44 @@ import (
 )
 
 func (s *State) Tokenize(input string) ([]string, error) {
-	if strings.Count(input, "=") > 1 {
-		return nil, errors.New("invalid double assignment")
-	}
 	tokens := make([]string, 0, len(input))
 	cur := ""
+	var quote rune // opening quote of the character or string literal being read
+	escaped := false
 	for _, char := range input {
+		if quote != 0 {
+			cur += string(char)
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\':
+				escaped = true
+			case char == quote:
+				tokens = append(tokens, cur)
+				cur, quote = "", 0
+			}
+			continue
+		}
+		if char == '\'' || char == '"' {
+			if len(cur) > 0 {
+				tokens = append(tokens, cur)
+			}
+			cur, quote = string(char), char
+			continue
+		}
 		numTokens := len(tokens)
 		if numTokens > 0 && tokens[numTokens-1] == "*" && char == '*' {
 			tokens[numTokens-1] = "**"
 			continue
 		}
+		// 1.5e-3 is a single literal and $-2 a reference to the result before last
+		if (char == '-' || char == '+') && isExponentPrefix(cur) || char == '-' && cur == "$" {
+			cur += string(char)
+			continue
+		}
 		if char == '(' ||
 			char == ')' ||
+			char == ',' ||
 			slices.Contains(Length1operatorsInfix, Operator(char)) ||
 			slices.Contains(Length1operatorsPrefix, Operator(char)) {
 			if len(cur) > 0 {
@@ -53,9 +78,30 @@ func (s *State) Tokenize(input string) ([]string, error) {
 			}
 		}
 	}
+	if quote != 0 {
+		return nil, errors.New("missing closing " + string(quote))
+	}
 	tokens = tokens[:len(tokens):len(tokens)]
 	if cur != "" {
 		tokens = append(tokens, cur)
 	}
+	assignments := 0
+	for _, token := range tokens {
+		if token == string(ASSIGN) {
+			assignments++
+		}
+	}
+	if assignments > 1 {
+		return nil, errors.New("invalid double assignment")
+	}
 	return tokens, nil
 }
+
+// isExponentPrefix reports whether cur is a decimal literal waiting for its exponent, as in 1.5e-3.
+func isExponentPrefix(cur string) bool {
+	if len(cur) < 2 || (cur[0] < '0' || cur[0] > '9') && cur[0] != '.' {
+		return false
+	}
+	last := cur[len(cur)-1]
+	return (last == 'e' || last == 'E') && !strings.HasPrefix(cur, "0x") && !strings.HasPrefix(cur, "0X")
+}
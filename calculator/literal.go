@@ -0,0 +1,124 @@
+package calculator
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// charLiteral reads a C character literal: 'A', '\n', '\x7f', 'é' or '\u00e9' give the code
+// point while multi-character literals such as 'RIFF' are packed first byte most significant, like GCC.
+func charLiteral(value string) (int64, error) {
+	if len(value) < 3 || value[len(value)-1] != '\'' {
+		return 0, errors.New("invalid character literal " + value)
+	}
+	b, err := unescapeC(value[1 : len(value)-1])
+	if err != nil {
+		return 0, err
+	}
+	if utf8.Valid(b) && utf8.RuneCount(b) == 1 {
+		r, _ := utf8.DecodeRune(b)
+		return int64(r), nil
+	}
+	return pack(b, true)
+}
+
+// stringLiteral packs the bytes of a string literal, "RIFF" being 0x46464952 in the
+// default little-endian order.
+func (s *State) stringLiteral(value string) (int64, error) {
+	if len(value) < 2 || value[len(value)-1] != '"' {
+		return 0, errors.New("invalid string literal " + value)
+	}
+	b, err := unescapeC(value[1 : len(value)-1])
+	if err != nil {
+		return 0, err
+	}
+	return pack(b, s.BigEndianStrings)
+}
+
+func pack(b []byte, bigEndian bool) (int64, error) {
+	if len(b) > 8 {
+		return 0, errors.New("literals are limited to 8 bytes")
+	}
+	var packed uint64
+	for i, c := range b {
+		if bigEndian {
+			packed = packed<<8 | uint64(c)
+		} else {
+			packed |= uint64(c) << (8 * i)
+		}
+	}
+	return int64(packed), nil //nolint:gosec // wrapping is what we want for bit patterns
+}
+
+var simpleEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', 'e': 0x1b,
+	'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// unescapeC decodes the C escape sequences of a literal's body, \u and \U becoming UTF-8.
+func unescapeC(body string) ([]byte, error) { //nolint:gocyclo // one case per escape kind
+	out := make([]byte, 0, len(body))
+	for i := 0; i < len(body); {
+		if body[i] != '\\' {
+			out = append(out, body[i])
+			i++
+			continue
+		}
+		if i+1 >= len(body) {
+			return nil, errors.New("incomplete escape sequence")
+		}
+		c := body[i+1]
+		i += 2
+		if escaped, ok := simpleEscapes[c]; ok {
+			out = append(out, escaped)
+			continue
+		}
+		switch {
+		case c == 'x':
+			end := i
+			for end < len(body) && end-i < 2 && isHexDigit(body[end]) {
+				end++
+			}
+			if end == i {
+				return nil, errors.New(`\x needs hex digits`)
+			}
+			v, _ := strconv.ParseUint(body[i:end], 16, 8)
+			out = append(out, byte(v))
+			i = end
+		case c == 'u' || c == 'U':
+			digits := 4
+			if c == 'U' {
+				digits = 8
+			}
+			if i+digits > len(body) {
+				return nil, errors.New(`\` + string(c) + " needs " + strconv.Itoa(digits) + " hex digits")
+			}
+			v, err := strconv.ParseUint(body[i:i+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(v)) {
+				return nil, errors.New("invalid code point " + body[i-2:i+digits])
+			}
+			out = utf8.AppendRune(out, rune(v))
+			i += digits
+		case c >= '0' && c <= '7':
+			start := i - 1
+			end := i
+			for end < len(body) && end-start < 3 && body[end] >= '0' && body[end] <= '7' {
+				end++
+			}
+			v, _ := strconv.ParseUint(body[start:end], 8, 16)
+			if v > 0xff {
+				return nil, errors.New("octal escape out of range " + body[start-1:end])
+			}
+			out = append(out, byte(v))
+			i = end
+		default:
+			return nil, errors.New("unknown escape sequence \\" + string(c))
+		}
+	}
+	return out, nil
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
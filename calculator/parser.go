@@ -3,6 +3,7 @@ package calculator
 import (
 	"errors"
 	"slices"
+	"unicode"
 )
 
 func (s *State) Parse(tokens []string) (CalcNode, error) {
@@ -29,9 +30,11 @@ func (s *State) parse(tokens []string, index int, cur *CalcNode) *CalcNode {
 		if index == 0 || index == len(tokens)-1 {
 			return nil
 		}
-		name := *cur.value
-		newNode = *s.parse(tokens[index+1:], 0, nil)
-		return &CalcNode{assignment: &assignment{name: name, right: newNode}}
+		right := s.parse(tokens[index+1:], 0, nil)
+		if cur == nil || cur.value == nil || right == nil {
+			return nil // nothing to assign to, as in f(1) = 2, or nothing to assign, as in x = )
+		}
+		return &CalcNode{assignment: &assignment{name: *cur.value, right: *right}}
 
 	case "<<", ">>", "**":
 		newNode.left = cur
@@ -44,21 +47,79 @@ func (s *State) parse(tokens []string, index int, cur *CalcNode) *CalcNode {
 		inner := innerParentheses(tokens[index+1:])
 		node := s.parse(inner, 0, nil)
 		if cur != nil {
-			cur.right = node
+			attach(cur, node)
 			return s.parse(tokens[index+1+len(inner):], 0, cur)
 		}
 		return s.parse(tokens[index+1+len(inner):], 0, node)
 	case ")":
 		return s.parse(tokens, index+1, cur)
 	default:
+		if index+1 < len(tokens) && tokens[index+1] == "(" && isIdentifier(token) {
+			return s.parseCall(tokens, index, cur)
+		}
 		if cur != nil {
-			cur.right = &newNode
+			attach(cur, &newNode)
 			return s.parse(tokens, index+1, cur)
 		}
 		return s.parse(tokens, index+1, &newNode)
 	}
 }
 
+// parseCall parses name(arg, ...) starting at tokens[index], the function name.
+func (s *State) parseCall(tokens []string, index int, cur *CalcNode) *CalcNode {
+	inner := innerParentheses(tokens[index+2:])
+	if inner == nil {
+		return nil
+	}
+	node := &CalcNode{call: &call{name: tokens[index]}}
+	start := 0
+	depth := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) && inner[i] == "(" {
+			depth++
+		}
+		if i < len(inner) && inner[i] == ")" {
+			depth--
+		}
+		if i < len(inner) && (inner[i] != "," || depth > 0) {
+			continue
+		}
+		if i == 0 && len(inner) == 0 {
+			break // no arguments
+		}
+		arg := s.parse(inner[start:i], 0, nil)
+		if arg == nil {
+			return nil
+		}
+		node.call.args = append(node.call.args, *arg)
+		start = i + 1
+	}
+	rest := tokens[index+2+len(inner)+1:]
+	if cur != nil {
+		attach(cur, node)
+		return s.parse(rest, 0, cur)
+	}
+	return s.parse(rest, 0, node)
+}
+
+// attach sets node as the right operand of cur, handing it down to a ~ still waiting for its operand.
+func attach(cur, node *CalcNode) {
+	if next := cur.right; next != nil && next.isNot() && (next.right == nil || next.right.isNot()) {
+		attach(next, node)
+		return
+	}
+	cur.right = node
+}
+
+func (n *CalcNode) isNot() bool {
+	return n.value != nil && *n.value == string(NOT)
+}
+
+func isIdentifier(token string) bool {
+	first := []rune(token)[0]
+	return unicode.IsLetter(first) || first == '_'
+}
+
 func innerParentheses(tokens []string) []string {
 	score := 0
 	for i, token := range tokens {
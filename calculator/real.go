@@ -0,0 +1,404 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+)
+
+// Mode selects the domain Exec evaluates expressions in.
+type Mode int
+
+const (
+	// IntegerMode is 64 bits two's complement arithmetic, / truncates.
+	IntegerMode Mode = iota
+	// FloatMode evaluates with float64.
+	FloatMode
+	// RationalMode evaluates with exact fractions (math/big.Rat).
+	RationalMode
+)
+
+var modeNames = []string{"int", "float", "rational"}
+
+func (m Mode) String() string {
+	return modeNames[m]
+}
+
+// ParseMode returns the mode called name ("int", "float" or "rational").
+func ParseMode(name string) (Mode, error) {
+	index := slices.Index(modeNames, name)
+	if index == -1 {
+		return IntegerMode, fmt.Errorf("unknown mode %q", name)
+	}
+	return Mode(index), nil
+}
+
+func init() {
+	register(
+		Function{Name: "sqrt", Params: []string{"x"}, Help: "square root", Real: realFunction(math.Sqrt)},
+		Function{Name: "ln", Params: []string{"x"}, Help: "natural logarithm", Real: realFunction(math.Log)},
+		Function{Name: "log2", Params: []string{"x"}, Help: "base 2 logarithm", Real: realFunction(math.Log2)},
+		Function{Name: "log10", Params: []string{"x"}, Help: "base 10 logarithm", Real: realFunction(math.Log10)},
+		Function{Name: "exp", Params: []string{"x"}, Help: "e to the power x", Real: realFunction(math.Exp)},
+		Function{
+			Name: "floor", Params: []string{"x"}, Help: "largest integer not above x",
+			Real: realFunction(math.Floor), Rat: ratFunction(ratFloor),
+		},
+		Function{
+			Name: "ceil", Params: []string{"x"}, Help: "smallest integer not below x",
+			Real: realFunction(math.Ceil), Rat: ratFunction(ratCeil),
+		},
+		Function{
+			Name: "round", Params: []string{"x"}, Help: "nearest integer, halves away from zero",
+			Real: realFunction(math.Round), Rat: ratFunction(ratRound),
+		},
+		Function{
+			Name: "trunc", Params: []string{"x"}, Help: "integer part of x",
+			Real: realFunction(math.Trunc), Rat: ratFunction(ratTrunc),
+		},
+		Function{
+			Name: "abs", Params: []string{"x"}, Help: "absolute value",
+			Real: realFunction(math.Abs), Rat: ratFunction(func(r *big.Rat) *big.Rat { return new(big.Rat).Abs(r) }),
+		},
+	)
+	intFunction := Functions["trunc"]
+	intFunction.Name, intFunction.Help = "int", "converts x to the integer domain, truncating"
+	register(intFunction)
+}
+
+func realFunction(fn func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		return fn(args[0]), nil
+	}
+}
+
+func ratFunction(fn func(*big.Rat) *big.Rat) func(args []*big.Rat) (*big.Rat, error) {
+	return func(args []*big.Rat) (*big.Rat, error) {
+		return fn(args[0]), nil
+	}
+}
+
+func ratFloor(r *big.Rat) *big.Rat {
+	// The denominator is always positive so Euclidean division rounds towards -inf.
+	return new(big.Rat).SetInt(new(big.Int).Div(r.Num(), r.Denom()))
+}
+
+func ratCeil(r *big.Rat) *big.Rat {
+	return new(big.Rat).Neg(ratFloor(new(big.Rat).Neg(r)))
+}
+
+func ratTrunc(r *big.Rat) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Quo(r.Num(), r.Denom()))
+}
+
+func ratRound(r *big.Rat) *big.Rat {
+	half := big.NewRat(int64(r.Sign()), 2)
+	return ratTrunc(half.Add(half, r))
+}
+
+// execReal evaluates node in FloatMode or RationalMode, keeping the exact result in
+// Real and its truncation to the integer domain in Ans.
+func (s *State) execReal(node CalcNode) error {
+	var result *big.Rat
+	var err error
+	if s.Mode == RationalMode {
+		result, err = s.evalRat(node)
+	} else {
+		var f float64
+		f, err = s.evalFloat(node)
+		if err == nil {
+			result, err = ratFromFloat(f)
+		}
+	}
+	s.Err = err
+	if err != nil {
+		return err
+	}
+	s.Real = result
+	s.Ans = s.Wrap(truncateRat(result))
+	return nil
+}
+
+// evalFloat evaluates arithmetic on float64, so literals like 1.5 or 1e-3 keep their fraction.
+// Bitwise operators require integral operands.
+func (s *State) evalFloat(curNode CalcNode) (float64, error) { //nolint:gocyclo // evaluation will be hairy
+	switch {
+	case curNode.assignment != nil:
+		f, err := s.evalFloat(curNode.assignment.right)
+		if err != nil {
+			return 0, err
+		}
+		r, err := ratFromFloat(f)
+		if err != nil {
+			return 0, err
+		}
+		s.setReal(curNode.assignment.name, r)
+		return f, nil
+	case curNode.call != nil:
+		return s.callFloat(*curNode.call)
+	case curNode.value == nil:
+		return 0, errors.New("bad value")
+	case curNode.isNegation():
+		if curNode.right == nil {
+			return 0, errors.New("invalid operator")
+		}
+		f, err := s.evalFloat(*curNode.right)
+		return -f, err
+	}
+	value := *curNode.value
+	switch value {
+	case "+", "-", "*", "/", "%", "**":
+		if curNode.left == nil || curNode.right == nil {
+			return 0, errors.New("invalid operator")
+		}
+		l, err := s.evalFloat(*curNode.left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := s.evalFloat(*curNode.right)
+		if err != nil {
+			return 0, err
+		}
+		switch value {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/":
+			return l / r, nil
+		case "%":
+			return math.Mod(l, r), nil
+		default:
+			return math.Pow(l, r), nil
+		}
+	}
+	if isOperator(value) {
+		num, err := s.integerOperator(curNode, func(n CalcNode) (int64, error) {
+			f, err := s.evalFloat(n)
+			if err != nil {
+				return 0, err
+			}
+			return exactInt(ratFromFloat(f))
+		})
+		return float64(num), err
+	}
+	if num, err := s.parseLiteral(value); err == nil {
+		return float64(num), nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+	if result, ok, err := s.reference(value); ok {
+		if err != nil || result.Real == nil {
+			return float64(result.Value), err
+		}
+		f, _ := result.Real.Float64()
+		return f, nil
+	}
+	if r, ok := s.Reals[value]; ok {
+		f, _ := r.Float64()
+		return f, nil
+	}
+	num, err := s.Eval(curNode)
+	return float64(num), err
+}
+
+// evalRat evaluates arithmetic exactly. Functions without an exact implementation go
+// through float64 and bitwise operators require integral operands.
+func (s *State) evalRat(curNode CalcNode) (*big.Rat, error) { //nolint:gocyclo,funlen // evaluation will be hairy
+	switch {
+	case curNode.assignment != nil:
+		r, err := s.evalRat(curNode.assignment.right)
+		if err != nil {
+			return nil, err
+		}
+		s.setReal(curNode.assignment.name, r)
+		return r, nil
+	case curNode.call != nil:
+		return s.callRat(*curNode.call)
+	case curNode.value == nil:
+		return nil, errors.New("bad value")
+	case curNode.isNegation():
+		if curNode.right == nil {
+			return nil, errors.New("invalid operator")
+		}
+		r, err := s.evalRat(*curNode.right)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).Neg(r), nil
+	}
+	value := *curNode.value
+	switch value {
+	case "+", "-", "*", "/", "%", "**":
+		if curNode.left == nil || curNode.right == nil {
+			return nil, errors.New("invalid operator")
+		}
+		l, err := s.evalRat(*curNode.left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := s.evalRat(*curNode.right)
+		if err != nil {
+			return nil, err
+		}
+		switch value {
+		case "+":
+			return new(big.Rat).Add(l, r), nil
+		case "-":
+			return new(big.Rat).Sub(l, r), nil
+		case "*":
+			return new(big.Rat).Mul(l, r), nil
+		case "/":
+			if r.Sign() == 0 {
+				return nil, errDivisionByZero
+			}
+			return new(big.Rat).Quo(l, r), nil
+		case "%":
+			if r.Sign() == 0 {
+				return nil, errDivisionByZero
+			}
+			quotient := ratTrunc(new(big.Rat).Quo(l, r))
+			return new(big.Rat).Sub(l, quotient.Mul(quotient, r)), nil
+		default:
+			return ratPow(l, r)
+		}
+	}
+	if isOperator(value) {
+		num, err := s.integerOperator(curNode, func(n CalcNode) (int64, error) {
+			return exactInt(s.evalRat(n))
+		})
+		return new(big.Rat).SetInt64(num), err
+	}
+	if num, err := s.parseLiteral(value); err == nil {
+		return new(big.Rat).SetInt64(num), nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsInf(f, 0) {
+			return nil, errors.New(value + " is out of range")
+		}
+		r, _ := new(big.Rat).SetString(value)
+		return r, nil
+	}
+	if result, ok, err := s.reference(value); ok {
+		if err != nil || result.Real == nil {
+			return new(big.Rat).SetInt64(result.Value), err
+		}
+		return new(big.Rat).Set(result.Real), nil
+	}
+	if r, ok := s.Reals[value]; ok {
+		return new(big.Rat).Set(r), nil
+	}
+	num, err := s.Eval(curNode)
+	return new(big.Rat).SetInt64(num), err
+}
+
+func isOperator(value string) bool {
+	return slices.Contains(Length1operatorsInfix, Operator(value[0])) ||
+		slices.Contains(Length1operatorsPrefix, Operator(value[0])) ||
+		slices.Contains(Length2operators, DoubleRuneOperator(value))
+}
+
+// integerOperator applies the bitwise and shift operators in the real modes, evaluating
+// the operands with eval.
+func (s *State) integerOperator(curNode CalcNode, eval func(CalcNode) (int64, error)) (int64, error) {
+	if curNode.right == nil {
+		return 0, errors.New("invalid operator")
+	}
+	r, err := eval(*curNode.right)
+	if err != nil {
+		return 0, err
+	}
+	if curNode.isNot() {
+		return ^r, nil
+	}
+	if curNode.left == nil {
+		return 0, errors.New("invalid operator")
+	}
+	l, err := eval(*curNode.left)
+	if err != nil {
+		return 0, err
+	}
+	return applyInt(*curNode.value, l, r)
+}
+
+// exactInt converts r for the bitwise operators which only make sense on integers.
+func exactInt(r *big.Rat, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() {
+		return 0, errors.New("bitwise operators need integers, got " + r.FloatString(6))
+	}
+	return truncateRat(r), nil
+}
+
+func ratPow(base, exp *big.Rat) (*big.Rat, error) {
+	if !exp.IsInt() || !exp.Num().IsInt64() || exp.Num().Int64() > 1<<12 || exp.Num().Int64() < -(1<<12) {
+		b, _ := base.Float64()
+		e, _ := exp.Float64()
+		return ratFromFloat(math.Pow(b, e))
+	}
+	n := exp.Num().Int64()
+	if n < 0 {
+		if base.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		base = new(big.Rat).Inv(base)
+		n = -n
+	}
+	num := new(big.Int).Exp(base.Num(), big.NewInt(n), nil)
+	den := new(big.Int).Exp(base.Denom(), big.NewInt(n), nil)
+	return new(big.Rat).SetFrac(num, den), nil
+}
+
+// ratFromFloat converts f, refusing NaN and infinities which fractions cannot represent.
+func ratFromFloat(f float64) (*big.Rat, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("result is not a finite number (%v)", f)
+	}
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+var uint64Mask = new(big.Int).SetUint64(math.MaxUint64)
+
+// truncateRat drops the fraction of r and wraps it to 64 bits like integer overflow does.
+func truncateRat(r *big.Rat) int64 {
+	integer := new(big.Int).Quo(r.Num(), r.Denom())
+	if integer.IsInt64() {
+		return integer.Int64()
+	}
+	return int64(integer.And(integer, uint64Mask).Uint64()) //nolint:gosec // wrapping is intended
+}
+
+func truncateFloat(f float64) (int64, error) {
+	r, err := ratFromFloat(f)
+	if err != nil {
+		return 0, err
+	}
+	return truncateRat(r), nil
+}
+
+func (s *State) setVariable(name string, value int64) {
+	s.Variables[name] = value
+	delete(s.Reals, name)
+}
+
+// DeleteVariable forgets a variable, whether it was assigned in integer or real mode.
+func (s *State) DeleteVariable(name string) bool {
+	_, ok := s.Variables[name]
+	delete(s.Variables, name)
+	delete(s.Reals, name)
+	return ok
+}
+
+// setReal stores a real valued variable, keeping its truncation visible to integer mode.
+func (s *State) setReal(name string, value *big.Rat) {
+	s.Reals[name] = value
+	s.Variables[name] = truncateRat(value)
+}
@@ -0,0 +1,168 @@
+package calculator
+
+import (
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Simplify rewrites n with 64-bit integer semantics: it folds constant sub-expressions,
+// removes identities such as x | 0 or x & -1 and merges chained shifts and masks.
+// Identifiers are left alone, they are the free variables of the expression.
+func (s *State) Simplify(n Node) Node {
+	n.Pos = -1
+	children := make([]Node, len(n.Children))
+	for i, child := range n.Children {
+		children[i] = s.Simplify(child)
+	}
+	n.Children = children
+	if n.Kind == Literal || n.Kind == Identifier || n.Kind == Assign {
+		return n
+	}
+	if value, ok := s.fold(n); ok {
+		return constant(value, n.hasHex())
+	}
+	if rewritten, ok := s.rewrite(n); ok {
+		return s.Simplify(rewritten)
+	}
+	return n
+}
+
+// fold evaluates n when it has no free variables.
+func (s *State) fold(n Node) (int64, bool) {
+	if n.hasIdentifier() {
+		return 0, false
+	}
+	scratch := State{BigEndianStrings: s.BigEndianStrings}
+	value, err := scratch.Evaluate(n.String())
+	return value, err == nil
+}
+
+func (n Node) hasIdentifier() bool {
+	if n.Kind == Identifier || n.Kind == Assign {
+		return true
+	}
+	for _, child := range n.Children {
+		if child.hasIdentifier() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHex reports whether n was written with hex literals, which its folded value keeps.
+func (n Node) hasHex() bool {
+	if n.Kind == Literal {
+		return strings.HasPrefix(strings.ToLower(n.Value), "0x")
+	}
+	for _, child := range n.Children {
+		if child.hasHex() {
+			return true
+		}
+	}
+	return false
+}
+
+// constant writes value as a literal, negative decimal values as a negation.
+func constant(value int64, hex bool) Node {
+	switch {
+	case hex:
+		return Node{Kind: Literal, Value: "0x" + strconv.FormatUint(uint64(value), 16), Pos: -1} //nolint:gosec // bit pattern
+	case value < 0 && value != -value:
+		return Node{Kind: Unary, Op: string(SUB), Children: []Node{constant(-value, false)}, Pos: -1}
+	}
+	return Node{Kind: Literal, Value: strconv.FormatUint(uint64(value), 10), Pos: -1} //nolint:gosec // MinInt64
+}
+
+// value returns the value of n when it is a constant, after Simplify folded it.
+func (s *State) value(n Node) (int64, bool) {
+	if n.Kind != Literal && n.Kind != Unary {
+		return 0, false
+	}
+	return s.fold(n)
+}
+
+var opposite = map[string]string{"+": "-", "-": "+"}
+
+// rewrite applies one identity or merge to n.
+func (s *State) rewrite(n Node) (Node, bool) { //nolint:gocyclo // one case per rule
+	if n.Kind == Unary {
+		if operand := n.Children[0]; operand.Kind == Unary && operand.Op == n.Op {
+			return operand.Children[0], true // ~~x and -(-x)
+		}
+		return n, false
+	}
+	if n.Kind != Binary {
+		return n, false
+	}
+	left, right := n.Children[0], n.Children[1]
+	r, rConst := s.value(right)
+	l, lConst := s.value(left)
+	hex := right.hasHex()
+	switch {
+	case rConst && r == 0 && slices.Contains([]string{"|", "^", "+", "-", "<<", ">>"}, n.Op),
+		rConst && r == -1 && n.Op == "&",
+		rConst && r == 1 && slices.Contains([]string{"*", "/", "**"}, n.Op):
+		return left, true
+	case lConst && l == 0 && slices.Contains([]string{"|", "^", "+"}, n.Op),
+		lConst && l == -1 && n.Op == "&",
+		lConst && l == 1 && n.Op == "*":
+		return right, true
+	case rConst && r == 0 && (n.Op == "&" || n.Op == "*"),
+		lConst && l == 0 && (n.Op == "&" || n.Op == "*"):
+		return constant(0, hex || left.hasHex()), true
+	case rConst && r == -1 && n.Op == "|":
+		return right, true
+	case right.Kind == Unary && right.Op == "-" && (n.Op == "+" || n.Op == "-"):
+		// x - (-y) is x + y
+		return Node{Kind: Binary, Op: opposite[n.Op], Children: []Node{left, right.Children[0]}, Pos: -1}, true
+	case !rConst || left.Kind != Binary:
+		return n, false
+	case n.Op == "&" && r >= 0 && s.isShiftedBack(left, r):
+		// (x << k) >> k & m is x & m when m is below the bits the shifts clear
+		return Node{Kind: Binary, Op: "&", Children: []Node{left.Children[0].Children[0], right}, Pos: -1}, true
+	}
+	inner, innerRight := left.Children[0], left.Children[1]
+	c, ok := s.value(innerRight)
+	if !ok {
+		return n, false
+	}
+	hex = hex || innerRight.hasHex()
+	merge := func(op string, value int64) (Node, bool) {
+		if (op == "+" || op == "-") && value < 0 && value != -value {
+			op, value = opposite[op], -value // x - 2 rather than x + (-2)
+		}
+		return Node{Kind: Binary, Op: op, Children: []Node{inner, constant(value, hex)}, Pos: -1}, true
+	}
+	shifts := c >= 0 && r >= 0 && c < 64 && r < 64
+	switch {
+	case n.Op == left.Op && slices.Contains([]string{"&", "|", "^", "+", "*"}, n.Op):
+		value, _ := applyInt(n.Op, c, r)
+		return merge(n.Op, value)
+	case n.Op == "-" && left.Op == "+", n.Op == "+" && left.Op == "-":
+		return merge(left.Op, c-r) // x + c - r is x + (c - r), x - c + r is x - (c - r)
+	case n.Op == "-" && left.Op == "-":
+		return merge("-", c+r)
+	case n.Op == "<<" && left.Op == "<<" && shifts:
+		if c+r >= 64 {
+			return constant(0, hex), true
+		}
+		return merge("<<", c+r)
+	case n.Op == ">>" && left.Op == ">>" && shifts:
+		return merge(">>", min(c+r, 63))
+	}
+	return n, false
+}
+
+// isShiftedBack reports whether shifted is (x << k) >> k and mask only has bits
+// in the low 64-k, where the shifts leave x as it was.
+func (s *State) isShiftedBack(shifted Node, mask int64) bool {
+	k, ok := s.value(shifted.Children[1])
+	left := shifted.Children[0]
+	if !ok || left.Kind != Binary || left.Op != "<<" || k < 0 || k > 63 {
+		return false
+	}
+	j, ok := s.value(left.Children[1])
+	return ok && j == k && bits.Len64(uint64(mask)) <= 64-int(k) //nolint:gosec // mask >= 0
+}
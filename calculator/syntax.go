@@ -0,0 +1,59 @@
+package calculator
+
+// Syntax describes an operator, a literal or a reference form for help screens.
+type Syntax struct {
+	Form string
+	Help string
+}
+
+var operatorHelp = map[string]string{
+	string(SUM):        "addition",
+	string(SUB):        "subtraction, or negation at the start of an expression or after (",
+	string(PROD):       "multiplication",
+	string(DIV):        "division, truncated toward zero in int mode",
+	string(MOD):        "remainder, with the sign of the dividend",
+	string(AND):        "bitwise and",
+	string(OR):         "bitwise or",
+	string(XOR):        "bitwise exclusive or",
+	string(NOT):        "bitwise not of the operand that follows",
+	string(ASSIGN):     "assign the right side to the variable on the left",
+	string(LEFTSHIFT):  "left shift, a trailing << shifts by 1",
+	string(RIGHTSHIFT): "arithmetic right shift, a trailing >> shifts by 1",
+	string(EXP):        "power, wrapping around in int mode",
+}
+
+// Operators describes every operator the lexer accepts, in the order of the operator lists.
+func Operators() []Syntax {
+	var forms []string
+	for _, op := range Length1operatorsInfix {
+		forms = append(forms, string(op))
+	}
+	for _, op := range Length1operatorsPrefix {
+		forms = append(forms, string(op))
+	}
+	for _, op := range append(Length2operators, EXP) {
+		forms = append(forms, string(op))
+	}
+	syntax := make([]Syntax, 0, len(forms))
+	for _, form := range forms {
+		syntax = append(syntax, Syntax{Form: form, Help: operatorHelp[form]})
+	}
+	return syntax
+}
+
+// Precedence is how operators group, the parser has no precedence levels.
+const Precedence = "every operator has the same precedence and applies left to right: " +
+	"1 + 2 * 3 is 9, group with parentheses"
+
+// Literals lists the forms parseLiteral and reference accept.
+var Literals = []Syntax{
+	{"42 0x2a 0o52 0b101010", "decimal, hex, octal and binary integers, _ separates digits"},
+	{"0xffffffffffffffff", "unsigned 64-bit values wrap to their signed bit pattern"},
+	{"'A' '\\n' '\\x7f' '\\u00e9'", "character code points with C escapes"},
+	{"'RIFF'", "multi-character literals, first byte most significant"},
+	{"\"RIFF\"", "string bytes, little-endian unless :strings be"},
+	{"name", "a variable, or a constant from :import"},
+	{"ans $$", "the last result"},
+	{"$-2 ans2", "the result before it, counting back from the last"},
+	{"$n _n", "the result numbered n in history"},
+}
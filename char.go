@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/runenames"
+)
+
+// controlNames are the ASCII abbreviations of the C0 control characters.
+var controlNames = [...]string{
+	"NUL", "SOH", "STX", "ETX", "EOT", "ENQ", "ACK", "BEL", "BS", "HT", "LF", "VT", "FF", "CR", "SO", "SI",
+	"DLE", "DC1", "DC2", "DC3", "DC4", "NAK", "SYN", "ETB", "CAN", "EM", "SUB", "ESC", "FS", "GS", "RS", "US",
+}
+
+var cEscapes = map[int64]string{
+	0: `\0`, 7: `\a`, 8: `\b`, 9: `\t`, 10: `\n`, 11: `\v`, 12: `\f`, 13: `\r`, '\\': `\\`, '\'': `\'`,
+}
+
+var categoryNames = map[string]string{
+	"Lu": "uppercase letter", "Ll": "lowercase letter", "Lt": "titlecase letter", "Lm": "modifier letter",
+	"Lo": "other letter", "Mn": "nonspacing mark", "Mc": "spacing mark", "Me": "enclosing mark",
+	"Nd": "decimal number", "Nl": "letter number", "No": "other number", "Pc": "connector punctuation",
+	"Pd": "dash punctuation", "Ps": "open punctuation", "Pe": "close punctuation", "Pi": "initial punctuation",
+	"Pf": "final punctuation", "Po": "other punctuation", "Sm": "math symbol", "Sc": "currency symbol",
+	"Sk": "modifier symbol", "So": "other symbol", "Zs": "space separator", "Zl": "line separator",
+	"Zp": "paragraph separator", "Cc": "control", "Cf": "format", "Co": "private use", "Cs": "surrogate",
+}
+
+func isCodePoint(num int64) bool {
+	return num >= 0 && num <= unicode.MaxRune && utf8.ValidRune(rune(num))
+}
+
+// controlName returns the abbreviation of an ASCII control character, "" for the others.
+func controlName(num int64) string {
+	switch {
+	case num >= 0 && num < int64(len(controlNames)):
+		return controlNames[num]
+	case num == 0x7f:
+		return "DEL"
+	}
+	return ""
+}
+
+// printableChar returns num as something safe to write to the terminal: the C escape
+// or caret notation of control characters and the character itself otherwise.
+func printableChar(num int64) string {
+	if escape, ok := cEscapes[num]; ok && num != '\\' && num != '\'' {
+		return escape
+	}
+	if caret := caretNotation(num); caret != "" {
+		return caret
+	}
+	if !unicode.IsPrint(rune(num)) {
+		return fmt.Sprintf("U+%04X", num)
+	}
+	return string(rune(num))
+}
+
+func caretNotation(num int64) string {
+	switch {
+	case num >= 0 && num < 0x20:
+		return "^" + string(rune(num+'@'))
+	case num == 0x7f:
+		return "^?"
+	}
+	return ""
+}
+
+func cEscape(num int64) string {
+	if escape, ok := cEscapes[num]; ok {
+		return escape
+	}
+	switch {
+	case num < 0x20 || num == 0x7f:
+		return fmt.Sprintf(`\x%02x`, num)
+	case num < 0x7f:
+		return string(rune(num))
+	case num <= 0xffff:
+		return fmt.Sprintf(`\u%04x`, num)
+	default:
+		return fmt.Sprintf(`\U%08x`, num)
+	}
+}
+
+func category(r rune) string {
+	for name, table := range unicode.Categories {
+		if len(name) == 2 && unicode.Is(table, r) {
+			return name + " (" + categoryNames[name] + ")"
+		}
+	}
+	return "Cn (unassigned)"
+}
+
+// packedString reads the bytes of num as text in memory order, like FourCC tags,
+// dropping the zero bytes of the unused high end.
+func packedString(num int64, order binary.ByteOrder) string {
+	var b [8]byte
+	order.PutUint64(b[:], uint64(num)) //nolint:gosec // reinterpreting bits
+	text := b[:]
+	if order == binary.LittleEndian {
+		text = []byte(strings.TrimRight(string(text), "\x00"))
+	} else {
+		text = []byte(strings.TrimLeft(string(text), "\x00"))
+	}
+	var sb strings.Builder
+	for _, c := range text {
+		if c < 0x20 || c >= 0x7f {
+			sb.WriteByte('.')
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return `"` + sb.String() + `"`
+}
+
+// charDisplayStrings is the character panel: num as a code point with its name, category,
+// escapes and encodings, followed by all 8 bytes read as a packed string.
+func charDisplayStrings(num int64, err error) []string {
+	display := []string{""}
+	if isCodePoint(num) {
+		r := rune(num)
+		name := runenames.Name(r)
+		if control := controlName(num); control != "" {
+			name = control + " " + name
+		}
+		caret := caretNotation(num)
+		if caret == "" {
+			caret = "-"
+		}
+		var utf8Bytes [utf8.UTFMax]byte
+		n := utf8.EncodeRune(utf8Bytes[:], r)
+		var utf16Units []string
+		for _, unit := range utf16.Encode([]rune{r}) {
+			utf16Units = append(utf16Units, fmt.Sprintf("%04x", unit))
+		}
+		display = append(display,
+			fmt.Sprintf("Char: %s  U+%04X %s", printableChar(num), num, name),
+			"Category: "+category(r),
+			"C escape: "+cEscape(num)+"  Caret: "+caret,
+			"UTF-8: "+hexBytes(utf8Bytes[:n])+"  UTF-16: "+strings.Join(utf16Units, " "),
+		)
+	} else {
+		display = append(display, "Char: not a Unicode code point", "", "", "")
+	}
+	display = append(display,
+		"Packed LE: "+packedString(num, binary.LittleEndian)+"  BE: "+packedString(num, binary.BigEndian))
+	display = append(display, bitGrid(num, byteColor)...)
+	if err != nil {
+		display[0] = paint(errorElement, "Last input was invalid")
+	}
+	return display
+}
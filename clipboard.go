@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+var (
+	// copyBases are the Alt shortcuts copying the result in a given base.
+	copyBases = map[string]int{"d": 10, "x": 16, "b": 2, "o": 8}
+	// copyBaseNames are the bases accepted by :copy and :copybase.
+	copyBaseNames = map[string]int{"dec": 10, "hex": 16, "bin": 2, "oct": 8}
+)
+
+// formatBase writes num like formatInt, decimal keeping the exact result of the float
+// and rational modes.
+func (c *config) formatBase(num int64, base int) string {
+	if exact := formatReal(c.state); exact != "" && num == c.state.Ans && base == 10 {
+		return exact
+	}
+	return formatInt(num, base)
+}
+
+// formatInt writes num the way it would be typed back in: prefixed for hex, binary and octal.
+func formatInt(num int64, base int) string {
+	switch base {
+	case 16:
+		return "0x" + strconv.FormatUint(uint64(num), 16) //nolint:gosec // bit pattern
+	case 2:
+		return "0b" + strconv.FormatUint(uint64(num), 2) //nolint:gosec // bit pattern
+	case 8:
+		return "0o" + strconv.FormatUint(uint64(num), 8) //nolint:gosec // bit pattern
+	}
+	return strconv.FormatInt(num, 10)
+}
+
+// copyResult puts the current result on the clipboard through OSC 52, which also works over ssh.
+func (c *config) copyResult(base int) {
+	text := c.formatBase(c.state.Ans, base)
+	c.AP.CopyToClipboard(text)
+	c.message = "copied " + text + " to the clipboard"
+}
+
+// copyEntry copies the selected history entry, or the last one, as "expression = result".
+func (c *config) copyEntry() {
+	i := c.curRecord
+	if i < 0 || i >= len(c.history) {
+		i = len(c.history) - 1
+	}
+	record := c.history[i]
+	text := record.evaluated + " = " + record.result()
+	c.AP.CopyToClipboard(text)
+	c.message = "copied " + text + " to the clipboard"
+}
+
+// handlePaste consumes bracketed paste data, which can span several reads, and reports
+// whether data was part of a paste.
+func (c *config) handlePaste(data string) bool {
+	if !c.pasting {
+		start := strings.Index(data, pasteStart)
+		if start == -1 {
+			return false
+		}
+		c.pasting = true
+		data = data[start+len(pasteStart):]
+	}
+	end := strings.Index(data, pasteEnd)
+	if end == -1 {
+		c.paste += data
+		return true
+	}
+	text := c.paste + data[:end]
+	c.pasting, c.paste = false, ""
+	c.feedPaste(text)
+	return true
+}
+
+// feedPaste enters every complete line of a paste as if typed and leaves the last,
+// unterminated, one in the input. Blank lines are skipped, Enter on an empty input
+// would repeat the last operation.
+func (c *config) feedPaste(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\t", " ")
+	lines := strings.Split(text, "\n")
+	for _, line := range lines[:len(lines)-1] {
+		c.insert(line)
+		if strings.TrimSpace(c.input) == "" {
+			c.input, c.index = "", 0
+			continue
+		}
+		c.handleEnter()
+	}
+	c.insert(lines[len(lines)-1])
+}
+
+// insert types text at the cursor, dropping control characters.
+func (c *config) insert(text string) {
+	text = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, text)
+	c.curRecord = -1
+	c.input = c.input[:c.index] + text + c.input[c.index:]
+	c.index += len(text)
+}
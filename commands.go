@@ -0,0 +1,553 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fortio.org/terminal/ansipixels/tcolor"
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// command is a ':' command, which controls tcalc itself instead of being evaluated by the
+// calculator. The registry also feeds Tab completion, the Ctrl+P palette and :help.
+type command struct {
+	name string
+	args string // usage of the arguments, "" when there are none
+	help string
+	// complete returns the candidates for the argument text typed so far, nil when
+	// there is nothing to offer.
+	complete func(c *config, args string) []string
+	run      func(c *config, args string) error
+}
+
+func (cmd command) usage() string {
+	return strings.TrimSpace(":" + cmd.name + " " + cmd.args)
+}
+
+// errUsage makes runCommand show the usage of the command.
+var errUsage = errors.New("usage")
+
+var commands = map[string]command{}
+
+func registerCommands(cmds ...command) {
+	for _, cmd := range cmds {
+		commands[cmd.name] = cmd
+	}
+}
+
+func commandNames() []string {
+	return slices.Sorted(maps.Keys(commands))
+}
+
+// choices completes from a fixed list of words.
+func choices(words ...string) func(*config, string) []string {
+	return func(*config, string) []string {
+		return words
+	}
+}
+
+// completeFiles lists the paths starting with the argument, directories ending with a slash.
+func completeFiles(_ *config, args string) []string {
+	matches, _ := filepath.Glob(args + "*")
+	for i, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			matches[i] = match + string(filepath.Separator)
+		}
+	}
+	return matches
+}
+
+func baseNames() []string {
+	return slices.Sorted(maps.Keys(copyBaseNames))
+}
+
+func parseBase(args string) (int, error) {
+	base, ok := copyBaseNames[args]
+	if !ok {
+		return 0, errUsage
+	}
+	return base, nil
+}
+
+var viewNames = map[string]view{
+	"int": integerView, "float": floatView, "bytes": bytesView, "char": charView, "compare": compareView,
+	"explain": explainView, "table": tableView,
+}
+
+func init() { //nolint:funlen,maintidx // one entry per command
+	registerCommands(
+		command{
+			name: "import", args: "path", help: "load the constants of a C header or Go file as variables",
+			complete: completeFiles,
+			run: func(c *config, args string) error {
+				if args == "" {
+					return errUsage
+				}
+				result, err := c.state.Import(args)
+				if err != nil {
+					return err
+				}
+				c.message = importSummary(args, result)
+				return nil
+			},
+		},
+		command{
+			name: "float", args: "16|32|64", help: "show the result as an IEEE-754 float of that width",
+			complete: choices("16", "32", "64"),
+			run: func(c *config, args string) error {
+				width, err := strconv.Atoi(args)
+				if _, ok := floatFormats[width]; !ok || err != nil {
+					return errUsage
+				}
+				c.floatWidth, c.view = width, floatView
+				return nil
+			},
+		},
+		command{
+			name: "view", args: "int|float|bytes|char|compare|explain|table", help: "choose the result panel, Tab cycles them",
+			complete: func(*config, string) []string { return slices.Sorted(maps.Keys(viewNames)) },
+			run: func(c *config, args string) error {
+				v, ok := viewNames[args]
+				if !ok {
+					return errUsage
+				}
+				c.view = v
+				return nil
+			},
+		},
+		command{
+			name: "mode", args: "int|float|rational", help: "evaluate with integers, float64 or exact fractions",
+			complete: choices("int", "float", "rational"),
+			run: func(c *config, args string) error {
+				mode, err := calculator.ParseMode(args)
+				if err != nil {
+					return errUsage
+				}
+				c.state.Mode = mode
+				return nil
+			},
+		},
+		command{
+			name: "width", args: "1-64", help: "wrap results and assigned values to that many bits, operators still work on 64",
+			complete: choices("8", "16", "32", "64"),
+			run: func(c *config, args string) error {
+				width, err := strconv.Atoi(args)
+				if err != nil || width < 1 || width > 64 {
+					return errUsage
+				}
+				c.state.Width = width
+				c.state.Ans = c.state.Wrap(c.state.Ans)
+				return nil
+			},
+		},
+		command{
+			name: "signedness", args: "signed|unsigned", help: "sign extend results narrower than 64 bits or not",
+			complete: choices("signed", "unsigned"),
+			run: func(c *config, args string) error {
+				if args != "signed" && args != "unsigned" {
+					return errUsage
+				}
+				c.state.Unsigned = args == "unsigned"
+				c.state.Ans = c.state.Wrap(c.state.Ans)
+				return nil
+			},
+		},
+		command{
+			name: "base", args: "dec|hex|bin|oct", help: "base of the variables panel and of copies",
+			complete: func(*config, string) []string { return baseNames() },
+			run: func(c *config, args string) error {
+				base, err := parseBase(args)
+				if err != nil {
+					return err
+				}
+				c.copyBase, c.vars.base = base, base
+				return nil
+			},
+		},
+		command{
+			name: "q", args: "Q15|Q1.14|UQ8.8", help: "fixed-point format of the Q row",
+			complete: choices("Q15", "Q31", "Q1.14", "UQ8.8"),
+			run: func(c *config, args string) error {
+				q, err := calculator.ParseQFormat(args)
+				if err != nil {
+					return err
+				}
+				c.qFormat = q
+				return nil
+			},
+		},
+		command{
+			name: "strings", args: "le|be", help: "byte order string literals are packed in",
+			complete: choices("le", "be"),
+			run: func(c *config, args string) error {
+				if args != "le" && args != "be" {
+					return errUsage
+				}
+				c.state.BigEndianStrings = args == "be"
+				return nil
+			},
+		},
+		command{
+			name: "copy", args: "[dec|hex|bin|oct|entry]", help: "copy the result or the history entry to the clipboard",
+			complete: func(*config, string) []string { return append(baseNames(), "entry") },
+			run: func(c *config, args string) error {
+				switch args {
+				case "", "result":
+					c.copyResult(c.copyBase)
+				case "entry":
+					c.copyEntry()
+				default:
+					base, err := parseBase(args)
+					if err != nil {
+						return err
+					}
+					c.copyResult(base)
+				}
+				return nil
+			},
+		},
+		command{
+			name: "copybase", args: "dec|hex|bin|oct", help: "base Ctrl+Y copies the result in",
+			complete: func(*config, string) []string { return baseNames() },
+			run: func(c *config, args string) error {
+				base, err := parseBase(args)
+				if err != nil {
+					return err
+				}
+				c.copyBase = base
+				return nil
+			},
+		},
+		command{
+			name: "compare", args: "[a, b|off]", help: "compare the bits of two expressions, the last two results by default",
+			complete: choices("off", "$-2, $$"),
+			run: func(c *config, args string) error {
+				if args == "off" {
+					c.compare, c.view = comparison{}, integerView
+					return nil
+				}
+				compare, err := parseComparison(args)
+				if err != nil {
+					return err
+				}
+				// both sides are evaluated on every frame, refuse the ones that can't be
+				for _, expr := range []string{compare.a, compare.b} {
+					if _, err := c.state.AST(expr); err != nil {
+						return errors.New(expr + ": " + err.Error())
+					}
+				}
+				c.compare, c.view = compare, compareView
+				return nil
+			},
+		},
+		command{
+			name: "explain", args: "[expression]",
+			help: "step through the evaluation of an expression, by default the one shown or the last input",
+			run: func(c *config, args string) error {
+				switch {
+				case args != "":
+				case c.view == explainView && c.explanation.input != "":
+					c.explanation.focused = true
+					return nil
+				default:
+					args = c.history[len(c.history)-1].evaluated
+				}
+				return c.explain(args)
+			},
+		},
+		command{
+			name: "simplify", args: "[expression]",
+			help: "fold constants, drop identities such as | 0 and merge shifts and masks, the last input by default",
+			run: func(c *config, args string) error {
+				if args == "" {
+					args = c.history[len(c.history)-1].evaluated
+				}
+				node, err := c.state.AST(args)
+				if err != nil {
+					return err
+				}
+				c.message = args + " simplifies to " + c.state.Simplify(node).String()
+				return nil
+			},
+		},
+		command{
+			name: "table", args: "[expression for x in 0..15[, y in a..b]]",
+			help: "evaluate an expression for every combination of ranges of its variables",
+			run: func(c *config, args string) error {
+				if args == "" && c.view == tableView && c.table.cells != nil {
+					c.table.focused = true
+					return nil
+				}
+				return c.showTable(args)
+			},
+		},
+		command{
+			name: "watch", args: "expression", help: "pin an expression, evaluated again after every input",
+			run: func(c *config, args string) error {
+				if args == "" {
+					return errUsage
+				}
+				return c.addWatch(args)
+			},
+		},
+		command{
+			name: "unwatch", args: "n|all", help: "remove a pinned expression",
+			complete: func(c *config, _ string) []string {
+				candidates := []string{"all"}
+				for i := range c.watches {
+					candidates = append(candidates, strconv.Itoa(i+1))
+				}
+				return candidates
+			},
+			run: func(c *config, args string) error { return c.removeWatch(args) },
+		},
+		command{
+			name: "vars", args: "[on|off|name|value|dec|hex|bin|oct]", help: "show, sort or change the base of the variables panel",
+			complete: func(*config, string) []string { return append([]string{"on", "off", "name", "value"}, baseNames()...) },
+			run: func(c *config, args string) error {
+				switch args {
+				case "", "on":
+					c.vars.shown = true
+				case "off":
+					c.vars.shown, c.vars.focused = false, false
+				case "name", "value":
+					c.vars.byValue = args == "value"
+				default:
+					base, err := parseBase(args)
+					if err != nil {
+						return err
+					}
+					c.vars.base = base
+				}
+				return nil
+			},
+		},
+		command{
+			name: "set", args: "name expression", help: "assign a variable without adding to history",
+			complete: func(c *config, _ string) []string { return c.sortedVariables() },
+			run: func(c *config, args string) error {
+				name, expr, ok := strings.Cut(args, " ")
+				if !ok {
+					return errUsage
+				}
+				err := c.state.Assign(name, strings.TrimSpace(expr))
+				c.refreshWatches()
+				return err
+			},
+		},
+		command{
+			name: "theme", args: "[name]", help: "switch the color theme",
+			complete: func(*config, string) []string { return themeNames() },
+			run: func(c *config, args string) error {
+				if args == "" {
+					c.message = "theme " + c.themeName + ", available: " + strings.Join(themeNames(), " ")
+					return nil
+				}
+				return c.setTheme(args)
+			},
+		},
+		command{
+			name: "color", args: "element color|none", help: "change the color of one element of the theme",
+			complete: func(_ *config, args string) []string {
+				element, _, ok := strings.Cut(args, " ")
+				if !ok {
+					return elementNames[:]
+				}
+				candidates := []string{element + " none"}
+				for _, name := range slices.Sorted(maps.Keys(tcolor.ColorMap)) {
+					candidates = append(candidates, element+" "+name)
+				}
+				return candidates
+			},
+			run: func(c *config, args string) error {
+				element, color, ok := strings.Cut(args, " ")
+				if !ok {
+					return errUsage
+				}
+				return c.setColor(element, strings.TrimSpace(color))
+			},
+		},
+		command{
+			name: "delete", args: "n", help: "delete the history entry numbered n",
+			complete: func(c *config, _ string) []string {
+				candidates := make([]string, 0, len(c.state.History))
+				for _, result := range c.state.History {
+					candidates = append(candidates, "$"+strconv.Itoa(result.ID))
+				}
+				return candidates
+			},
+			run: func(c *config, args string) error {
+				id, err := strconv.Atoi(strings.TrimLeft(args, "$_"))
+				if err != nil {
+					return errUsage
+				}
+				if !c.deleteRecord(id) {
+					return errors.New("no result $" + strconv.Itoa(id))
+				}
+				c.message = "deleted $" + strconv.Itoa(id)
+				return nil
+			},
+		},
+		command{
+			name: "clear", args: "[history|vars|all]", help: "forget the history, the variables or both",
+			complete: choices("history", "vars", "all"),
+			run: func(c *config, args string) error {
+				switch args {
+				case "", "history":
+					c.clearHistory()
+				case "vars":
+					c.clearVariables()
+				case "all":
+					c.clearHistory()
+					c.clearVariables()
+				default:
+					return errUsage
+				}
+				return nil
+			},
+		},
+		command{
+			name: "save", args: "path", help: "save the settings, history, variables and watches as a script",
+			complete: completeFiles,
+			run: func(c *config, args string) error {
+				if args == "" {
+					return errUsage
+				}
+				return c.saveSession(args)
+			},
+		},
+		command{
+			name: "load", args: "path", help: "replay a script written by :save",
+			complete: completeFiles,
+			run: func(c *config, args string) error {
+				if args == "" {
+					return errUsage
+				}
+				return c.loadSession(args)
+			},
+		},
+		command{
+			name: "export", args: "path [csv|json|markdown|tcalc|text]",
+			help: "write the history to a file, in the format of its extension by default",
+			complete: func(c *config, args string) []string {
+				path, _, ok := strings.Cut(args, " ")
+				if !ok {
+					return completeFiles(c, args)
+				}
+				candidates := make([]string, 0, len(exportFormats))
+				for _, format := range exportFormatNames() {
+					candidates = append(candidates, path+" "+format)
+				}
+				return candidates
+			},
+			run: func(c *config, args string) error {
+				path, format, _ := strings.Cut(args, " ")
+				if path == "" {
+					return errUsage
+				}
+				return c.exportHistory(path, strings.TrimSpace(format))
+			},
+		},
+		command{
+			name: "help", args: "[command]", help: "describe a command, Ctrl+P searches them all",
+			complete: func(*config, string) []string { return commandNames() },
+			run: func(c *config, args string) error {
+				if args == "" {
+					c.message = "commands: " + strings.Join(commandNames(), " ") + ", Ctrl+P to search them"
+					return nil
+				}
+				cmd, ok := commands[strings.TrimPrefix(args, ":")]
+				if !ok {
+					return errors.New("unknown command :" + args)
+				}
+				c.message = cmd.usage() + ": " + cmd.help
+				return nil
+			},
+		},
+	)
+}
+
+// execCommand runs a ':' command line.
+func (c *config) execCommand(line string) error {
+	name, args, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	cmd, ok := commands[name]
+	if !ok {
+		return errors.New("unknown command :" + name)
+	}
+	err := cmd.run(c, strings.TrimSpace(args))
+	if errors.Is(err, errUsage) {
+		return errors.New("usage: " + cmd.usage())
+	}
+	return err
+}
+
+// runCommand handles input starting with ':', showing errors as the message.
+func (c *config) runCommand(line string) {
+	if err := c.execCommand(line); err != nil {
+		c.errorMessage(err.Error())
+	}
+}
+
+// completeCommand is Tab on a ':' input line: it completes the command name or its
+// argument as far as the candidates agree and lists them when they don't.
+func (c *config) completeCommand() {
+	name, args, hasArgs := strings.Cut(strings.TrimPrefix(c.input, ":"), " ")
+	prefix, word := ":", name
+	var candidates []string
+	if !hasArgs {
+		candidates = commandNames()
+	} else if cmd, ok := commands[name]; ok && cmd.complete != nil {
+		word = strings.TrimLeft(args, " ")
+		prefix, candidates = ":"+name+" ", cmd.complete(c, word)
+	}
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		c.input = prefix + matches[0]
+		if !hasArgs && commands[matches[0]].args != "" {
+			c.input += " "
+		}
+	default:
+		c.input = prefix + commonPrefix(matches)
+		c.message = strings.Join(matches, " ")
+	}
+	c.index = len(c.input)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func (c *config) errorMessage(msg string) {
+	c.message = paint(errorElement, msg)
+}
+
+func importSummary(path string, result calculator.ImportResult) string {
+	summary := "imported " + strconv.Itoa(len(result.Loaded)) + " constants from " + path
+	if len(result.Skipped) == 0 {
+		return summary
+	}
+	skipped := make([]string, 0, len(result.Skipped))
+	for _, constant := range result.Skipped {
+		skipped = append(skipped, constant.Name+" ("+constant.Err.Error()+")")
+	}
+	return summary + ", skipped " + strconv.Itoa(len(skipped)) + ": " + strings.Join(skipped, ", ")
+}
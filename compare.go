@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// comparison is the pair of expressions shown by the compare view, they are evaluated
+// again on every frame so that they follow the variables they use.
+type comparison struct {
+	a, b string
+}
+
+// parseComparison reads ":compare" arguments: two expressions separated by a comma outside
+// of parentheses, two space separated words such as "$3 $5", or nothing for the last two results.
+func parseComparison(args string) (comparison, error) {
+	if args == "" {
+		return comparison{"$-2", "$$"}, nil
+	}
+	depth := 0
+	for i, r := range args {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				return comparison{strings.TrimSpace(args[:i]), strings.TrimSpace(args[i+1:])}, nil
+			}
+		}
+	}
+	if fields := strings.Fields(args); len(fields) == 2 {
+		return comparison{fields[0], fields[1]}, nil
+	}
+	return comparison{}, errors.New("usage: :compare a, b")
+}
+
+func (c *config) compareDisplayStrings() []string {
+	if c.compare.a == "" {
+		return []string{"", "Compare: use :compare a, b or :compare $3 $5"}
+	}
+	a, errA := c.state.Evaluate(c.compare.a)
+	b, errB := c.state.Evaluate(c.compare.b)
+	if err := errors.Join(errA, errB); err != nil {
+		return []string{"", paint(errorElement, "Compare: "+strings.ReplaceAll(err.Error(), "\n", ", "))}
+	}
+	display := compareDisplayStrings(a, b, c.compare.a, c.compare.b)
+	if c.state.Err != nil {
+		display[0] = paint(errorElement, "Last input was invalid")
+	}
+	return display
+}
+
+// compareDisplayStrings shows the bit grids of a and b one above the other, the bits
+// that differ colored as in the changed bits of the integer view.
+func compareDisplayStrings(a, b int64, exprA, exprB string) []string {
+	diff := uint64(a ^ b) //nolint:gosec // bit pattern
+	display := []string{
+		"",
+		fmt.Sprintf("A %s = %#x (%d)", exprA, uint64(a), a), //nolint:gosec // bit pattern
+		fmt.Sprintf("B %s = %#x (%d)", exprB, uint64(b), b), //nolint:gosec // bit pattern
+		fmt.Sprintf("a ^ b  %#x, %d bits differ", diff, bits.OnesCount64(diff)),
+		fmt.Sprintf("a & ~b %#x", uint64(a&^b)), //nolint:gosec // bit pattern
+		fmt.Sprintf("~a & b %#x", uint64(^a&b)), //nolint:gosec // bit pattern
+		"A",
+	}
+	display = append(display, bitGrid(a, changedBitColor(a, b))...)
+	display = append(display, "B")
+	return append(display, bitGrid(b, changedBitColor(b, a))...)
+}
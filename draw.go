@@ -1,11 +1,15 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
+	"math/bits"
+	"slices"
 	"strconv"
 	"strings"
 
 	"fortio.org/terminal/ansipixels/tcolor"
+	"github.com/geofpwhite/tcalc/calculator"
 )
 
 const (
@@ -14,13 +18,100 @@ const (
 	binaryString  string = "Binary: \n"
 )
 
+// view selects how the result panel interprets Ans, Tab cycles through them.
+type view int
+
+const (
+	integerView view = iota
+	floatView
+	bytesView
+	charView
+	compareView
+	explainView
+	tableView
+	numViews
+)
+
+func (c *config) resultStrings() []string {
+	var display []string
+	switch c.view {
+	case floatView:
+		display = floatDisplayStrings(c.state.Ans, c.floatWidth, c.state.Err)
+	case bytesView:
+		display = bytesDisplayStrings(c.state.Ans, c.state.Err)
+	case charView:
+		display = charDisplayStrings(c.state.Ans, c.state.Err)
+	case compareView:
+		display = c.compareDisplayStrings()
+	case explainView:
+		display = c.explainDisplayStrings()
+	case tableView:
+		display = c.tableDisplayStrings()
+	default:
+		var previous *int64
+		if value, ok := c.previousValue(); ok {
+			previous = &value
+		}
+		display = displayString(c.state.Ans, c.state.Err, c.qFormat, previous)
+	}
+	if line := realDisplayString(c.state); line != "" {
+		display = slices.Insert(display, 1, line)
+	}
+	return display
+}
+
+// formatReal returns the exact result of the last evaluation in float or rational mode,
+// or "" in integer mode.
+func formatReal(s *calculator.State) string {
+	if s.Real == nil {
+		return ""
+	}
+	if s.Mode == calculator.RationalMode {
+		return s.Real.RatString()
+	}
+	f, _ := s.Real.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func realDisplayString(s *calculator.State) string {
+	value := formatReal(s)
+	switch {
+	case value == "":
+		return ""
+	case s.Mode != calculator.RationalMode:
+		return "Float: " + value
+	case s.Real.IsInt():
+		return "Rational: " + value
+	default:
+		f, _ := s.Real.Float64()
+		return "Rational: " + value + " ≈ " + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
 func binaryDisplayStrings(num int64) []string {
+	return bitGrid(num, nil)
+}
+
+// bitGrid lays out the 64 bits of num in 4 rows of 16, colouring each bit with
+// bitColor(bit) when it is given and returns a non empty color, with the set and clear
+// bit colors of the theme otherwise.
+func bitGrid(num int64, bitColor func(bit int) string) []string {
 	var rows [4][4][]string
 	var j, k, w int
 	for i := 63; i > -1; i-- {
 		value := (int(((1 << i) & num) >> i))
 		value = max(value, -value)
 		valueString := strconv.Itoa(value)
+		color := colors[clearBitElement]
+		if value == 1 {
+			color = colors[setBitElement]
+		}
+		if bitColor != nil {
+			color = cmp.Or(bitColor(i), color)
+		}
+		if color != "" {
+			valueString = color + valueString + tcolor.Reset
+		}
 		if rows[j][k] == nil { //nolint:gosec // we are doing some math to ensure we stay in bounds
 			rows[j][k] = make([]string, 4)
 		}
@@ -63,32 +154,93 @@ func hexDisplayString(num int64) string {
 	return hexString + fmt.Sprintf("%x\n", uint64(num))
 }
 
-func displayString(num int64, err error) []string {
-	display := append([]string{
+// qDisplayString interprets num as a raw fixed-point value in format q.
+func qDisplayString(num int64, q calculator.QFormat) string {
+	value := q.Value(num).FloatString(q.N)
+	if strings.Contains(value, ".") {
+		value = strings.TrimRight(strings.TrimRight(value, "0"), ".")
+	}
+	return q.String() + ": " + value
+}
+
+// displayString is the integer view, with the bits that changed since previous
+// highlighted when it is given.
+func displayString(num int64, err error, q calculator.QFormat, previous *int64) []string {
+	display := []string{
 		"",
 		ASCII(num),
 		decimalDisplayString(num),
 		uintDisplayString(num),
 		hexDisplayString(num),
-	},
-		binaryDisplayStrings(num)...)
+		qDisplayString(num, q),
+	}
+	if previous == nil {
+		display = append(display, binaryDisplayStrings(num)...)
+	} else {
+		display = append(display, diffDisplayString(num, *previous))
+		display = append(display, bitGrid(num, changedBitColor(num, *previous))...)
+	}
 	if err != nil {
-		display[0] = tcolor.Red.Foreground() + "Last input was invalid" + tcolor.Reset
+		display[0] = paint(errorElement, "Last input was invalid")
 	}
 	return display
 }
 
+// ASCII is the one line summary of num as a character, see charDisplayStrings for the full panel.
 func ASCII(num int64) string {
-	switch num {
-	case 12:
-		return "ASCII: "
-	case 7:
-		return "ASCII: "
-	case 10:
-		return "ASCII: \\n"
-	case 11:
-		return "ASCII: \\r"
-	default:
+	switch {
+	case num >= 0 && num < 0x80:
+		if control := controlName(num); control != "" {
+			return "ASCII: " + printableChar(num) + " (" + control + ")"
+		}
 		return "ASCII: " + string(rune(num))
+	case isCodePoint(num):
+		return fmt.Sprintf("Unicode: %s U+%04X", printableChar(num), num)
+	default:
+		return "ASCII: none"
+	}
+}
+
+// previousValue is what the integer view compares Ans with: the last result when Ans
+// changed since (clicked bits, recalled entry), otherwise the result before it.
+func (c *config) previousValue() (int64, bool) {
+	n := len(c.history)
+	if last := c.history[n-1].finalValue; c.state.Ans != last {
+		return last, true
+	}
+	if n < 2 {
+		return 0, false
+	}
+	return c.history[n-2].finalValue, true
+}
+
+// diffDisplayString summarises num ^ previous.
+func diffDisplayString(num, previous int64) string {
+	changed := uint64(num ^ previous) //nolint:gosec // bit pattern
+	if changed == 0 {
+		return "Changed: none"
+	}
+	count := bits.OnesCount64(changed)
+	highest, lowest := 63-bits.LeadingZeros64(changed), bits.TrailingZeros64(changed)
+	return fmt.Sprintf("Changed: %d (+%s -%s) bits %d..%d ^0x%x",
+		count,
+		paint(newlySetElement, strconv.Itoa(bits.OnesCount64(changed&uint64(num)))),          //nolint:gosec // bit pattern
+		paint(newlyClearedElement, strconv.Itoa(bits.OnesCount64(changed&uint64(previous)))), //nolint:gosec // bit pattern
+		highest, lowest, changed)
+}
+
+// changedBitColor colors the bits of num that differ from previous, telling the ones
+// that got set from the ones that got cleared.
+func changedBitColor(num, previous int64) func(bit int) string {
+	changed := num ^ previous
+	return func(bit int) string {
+		switch {
+		case changed>>bit&1 == 0:
+			return ""
+		case num>>bit&1 == 1:
+			return colors[newlySetElement]
+		default:
+			return colors[newlyClearedElement]
+		}
 	}
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// explanation is the :explain view, revealing the value of each sub-expression of an
+// input in the order they are evaluated.
+type explanation struct {
+	input   string
+	steps   []calculator.Step
+	step    int  // the steps after this one are not revealed yet
+	table   bool // a table in evaluation order rather than the expression tree
+	focused bool // keys step through the evaluation instead of editing the input
+}
+
+func (c *config) explain(input string) error {
+	steps, err := c.state.Explain(input)
+	if err != nil {
+		return err
+	}
+	c.explanation = explanation{input: input, steps: steps, focused: true}
+	c.view = explainView
+	return nil
+}
+
+func (c *config) handleExplainKey(key string) {
+	e := &c.explanation
+	switch key {
+	case "\x1b", "q", "\r", "\n": // escape
+		e.focused = false
+	case "\x1b[C", "\x1b[B", " ", "n": // right, down
+		e.step = min(e.step+1, len(e.steps)-1)
+	case "\x1b[D", "\x1b[A", "\x7f", "p": // left, up
+		e.step = max(e.step-1, 0)
+	case "\x1b[H", "g": // home
+		e.step = 0
+	case "\x1b[F", "G": // end
+		e.step = len(e.steps) - 1
+	case "t":
+		e.table = !e.table
+	}
+}
+
+// stepValue shows a value in hex and binary, with the exact value in the float and rational modes.
+func stepValue(step calculator.Step) string {
+	if step.Err != nil {
+		return paint(errorElement, step.Err.Error())
+	}
+	value := strconv.FormatInt(step.Value, 10)
+	if step.Real != nil && !step.Real.IsInt() {
+		f, _ := step.Real.Float64()
+		value = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return value + "  " + formatInt(step.Value, 16) + "  " + formatInt(step.Value, 2)
+}
+
+func (c *config) explainDisplayStrings() []string {
+	e := c.explanation
+	if len(e.steps) == 0 {
+		return []string{"", "Explain: use :explain expression"}
+	}
+	keys := "←→ step, t table, Esc leaves"
+	switch {
+	case !e.focused:
+		keys = ":explain steps again"
+	case e.table:
+		keys = "←→ step, t tree, Esc leaves"
+	}
+	display := []string{"", fmt.Sprintf("Explain %s, step %d/%d (%s)", e.input, e.step+1, len(e.steps), keys)}
+	line := func(i int, text string) string {
+		if i == e.step {
+			return paint(selectionElement, text)
+		}
+		return text
+	}
+	value := func(i int) string {
+		if i > e.step {
+			return "…"
+		}
+		return stepValue(e.steps[i])
+	}
+	if e.table {
+		width := 0
+		for _, step := range e.steps {
+			width = max(width, len(step.Expr))
+		}
+		for i, step := range e.steps {
+			display = append(display, line(i, fmt.Sprintf("%2d  %-*s  %s", i+1, width, step.Expr, value(i))))
+		}
+	} else {
+		var walk func(i int)
+		walk = func(i int) {
+			step := e.steps[i]
+			display = append(display, line(i, strings.Repeat("  ", step.Depth)+step.Expr+" = "+value(i)))
+			for j := range i {
+				if e.steps[j].Parent == i {
+					walk(j)
+				}
+			}
+		}
+		walk(len(e.steps) - 1)
+	}
+	return append(display, bitGrid(e.steps[e.step].Value, nil)...)
+}
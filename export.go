@@ -0,0 +1,174 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// exportFormats write the history for :export, "text" being expression = result lines.
+var exportFormats = map[string]func(c *config) (string, error){
+	"text":     exportText,
+	"markdown": func(c *config) (string, error) { return joinLines(tableFormats["markdown"](c.exportCells())), nil },
+	"csv":      func(c *config) (string, error) { return joinLines(tableFormats["csv"](c.exportCells())), nil },
+	"json":     exportJSON,
+	"tcalc":    exportScript,
+}
+
+func exportFormatNames() []string {
+	return slices.Sorted(maps.Keys(exportFormats))
+}
+
+// exportExtensions pick the format from the file name when :export isn't given one.
+var exportExtensions = map[string]string{".md": "markdown", ".markdown": "markdown", ".csv": "csv", ".json": "json", ".tcalc": "tcalc"}
+
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (c *config) exportHistory(path, format string) error {
+	if format == "" {
+		format = cmp.Or(exportExtensions[strings.ToLower(filepath.Ext(path))], "text")
+	}
+	write, ok := exportFormats[format]
+	if !ok {
+		return errors.New("unknown format " + format + ", one of " + strings.Join(exportFormatNames(), " "))
+	}
+	text, err := write(c)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		return err
+	}
+	c.message = "exported " + strconv.Itoa(len(c.history)-1) + " entries to " + path + " as " + format
+	return nil
+}
+
+func exportText(c *config) (string, error) {
+	var b strings.Builder
+	for _, record := range c.history[1:] {
+		b.WriteString(record.evaluated + " = " + record.result() + "\n")
+	}
+	return b.String(), nil
+}
+
+// exportBase is the base of the variables panel and copies, set with :base, which the
+// exports add a column for unless it is decimal.
+func (c *config) exportBase() (string, bool) {
+	for name, base := range copyBaseNames {
+		if base == c.vars.base && base != 10 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (c *config) exportCells() [][]string {
+	header := []string{"#", "expression", "result"}
+	baseName, hasBase := c.exportBase()
+	if hasBase {
+		header = append(header, baseName)
+	}
+	cells := [][]string{append(header, "time")}
+	for _, record := range c.history[1:] {
+		row := []string{"$" + strconv.Itoa(record.id), record.evaluated, record.result()}
+		if hasBase {
+			row = append(row, formatInt(record.finalValue, c.vars.base))
+		}
+		cells = append(cells, append(row, record.time.Format(time.RFC3339)))
+	}
+	return cells
+}
+
+type exportedEntry struct {
+	ID         int               `json:"id"`
+	Expression string            `json:"expression"`
+	Result     string            `json:"result"`
+	Bases      map[string]string `json:"bases,omitempty"`
+	Mode       string            `json:"mode"`
+	Time       time.Time         `json:"time"`
+}
+
+func exportJSON(c *config) (string, error) {
+	entries := make([]exportedEntry, 0, len(c.history)-1)
+	baseName, hasBase := c.exportBase()
+	for _, record := range c.history[1:] {
+		entry := exportedEntry{
+			ID: record.id, Expression: record.evaluated, Result: record.result(),
+			Mode: record.mode.String(), Time: record.time,
+		}
+		if hasBase {
+			entry.Bases = map[string]string{baseName: formatInt(record.finalValue, c.vars.base)}
+		}
+		entries = append(entries, entry)
+	}
+	text, err := json.MarshalIndent(entries, "", "  ")
+	return string(text) + "\n", err
+}
+
+// exportScript writes a script that :load replays through State.Exec to get the same
+// results and variables: the variables the history doesn't assign come first, then
+// every entry under the settings it was evaluated with.
+func exportScript(c *config) (string, error) {
+	lines := []string{"# tcalc history exported " + time.Now().Format(time.RFC3339)}
+	assigned := map[string]bool{}
+	for _, record := range c.history[1:] {
+		if node, err := c.state.AST(record.evaluated); err == nil && node.Kind == calculator.Assign {
+			assigned[node.Name] = true
+		}
+	}
+	for _, name := range c.sortedVariables() {
+		if assigned[name] {
+			continue
+		}
+		value := strconv.FormatInt(c.state.Variables[name], 10)
+		if exact, ok := c.state.Reals[name]; ok {
+			value = exact.RatString()
+		}
+		lines = append(lines, ":set "+name+" "+value)
+	}
+	history, _ := c.historyScript(true)
+	return joinLines(append(lines, history...)), nil
+}
+
+// renumber rewrites the references to results of expr, $n and _n, with their number
+// in ids. Only the references change, the rest of expr stays as written: reformatting
+// could turn "(-5) + $2" into "-5 + $1", which Enter would apply to the last result.
+func (c *config) renumber(expr string, ids map[int]int) string {
+	node, err := c.state.AST(expr)
+	if err != nil {
+		return expr
+	}
+	var references []calculator.Node
+	var walk func(n calculator.Node)
+	walk = func(n calculator.Node) {
+		for _, child := range n.Children {
+			walk(child)
+		}
+		if n.Kind == calculator.Identifier && len(n.Value) > 1 && (n.Value[0] == '$' || n.Value[0] == '_') && n.Pos >= 0 {
+			if _, isVariable := c.state.Variables[n.Value]; !isVariable {
+				references = append(references, n)
+			}
+		}
+	}
+	walk(node)
+	// from the end so that the offsets of the earlier references stay valid
+	slices.SortFunc(references, func(a, b calculator.Node) int { return b.Pos - a.Pos })
+	for _, ref := range references {
+		id, err := strconv.Atoi(ref.Value[1:])
+		if newID, ok := ids[id]; err == nil && ok {
+			expr = expr[:ref.Pos] + ref.Value[:1] + strconv.Itoa(newID) + expr[ref.Pos+len(ref.Value):]
+		}
+	}
+	return expr
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// floatFormat describes one of the IEEE-754 binary interchange formats.
+type floatFormat struct {
+	name         string
+	width        int
+	exponentBits int
+	bias         int
+}
+
+var floatFormats = map[int]floatFormat{
+	16: {"Half precision (f16)", 16, 5, 15},
+	32: {"Single precision (f32)", 32, 8, 127},
+	64: {"Double precision (f64)", 64, 11, 1023},
+}
+
+func (f floatFormat) mantissaBits() int {
+	return f.width - f.exponentBits - 1
+}
+
+// value reinterprets the low bits of num in this format.
+func (f floatFormat) value(num int64) float64 {
+	switch f.width {
+	case 16:
+		return calculator.Float16Value(uint16(num)) //nolint:gosec // only the low 16 bits are meant
+	case 32:
+		return float64(math.Float32frombits(uint32(num))) //nolint:gosec // only the low 32 bits are meant
+	default:
+		return math.Float64frombits(uint64(num)) //nolint:gosec // reinterpreting bits
+	}
+}
+
+func (f floatFormat) fields(num int64) (sign, exponent, mantissa uint64) {
+	bits := uint64(num) //nolint:gosec // reinterpreting bits
+	mantissa = bits & (1<<f.mantissaBits() - 1)
+	exponent = bits >> f.mantissaBits() & (1<<f.exponentBits - 1)
+	sign = bits >> (f.width - 1) & 1
+	return sign, exponent, mantissa
+}
+
+func (f floatFormat) classify(exponent, mantissa uint64) string {
+	maxExponent := uint64(1<<f.exponentBits - 1)
+	switch {
+	case exponent == 0 && mantissa == 0:
+		return "zero"
+	case exponent == 0:
+		return "subnormal"
+	case exponent == maxExponent && mantissa == 0:
+		return "infinity"
+	case exponent == maxExponent && mantissa>>(f.mantissaBits()-1) == 1:
+		return "quiet NaN"
+	case exponent == maxExponent:
+		return "signaling NaN"
+	default:
+		return "normal"
+	}
+}
+
+func (f floatFormat) bitColor(bit int) string {
+	switch {
+	case bit >= f.width:
+		return colors[dimElement]
+	case bit == f.width-1:
+		return colors[signElement]
+	case bit >= f.mantissaBits():
+		return colors[exponentElement]
+	default:
+		return colors[mantissaElement]
+	}
+}
+
+// floatDisplayStrings is the float view counterpart of displayString: the low width bits
+// of num read as an IEEE-754 float, with the sign, exponent and mantissa fields coloured on the bit grid.
+func floatDisplayStrings(num int64, width int, err error) []string {
+	format := floatFormats[width]
+	sign, exponent, mantissa := format.fields(num)
+	class := format.classify(exponent, mantissa)
+	power := int(exponent) - format.bias //nolint:gosec // exponent has at most 11 bits
+	if exponent == 0 {
+		power = 1 - format.bias
+	}
+	bitSize := 64
+	if width == 32 {
+		bitSize = 32
+	}
+	display := []string{
+		"",
+		format.name,
+		"Value: " + strconv.FormatFloat(format.value(num), 'g', -1, bitSize),
+		"Class: " + class,
+		fmt.Sprintf("%s %d  %s 0x%x (2^%d)  %s 0x%x",
+			paint(signElement, "Sign"), sign, paint(exponentElement, "Exponent"), exponent, power,
+			paint(mantissaElement, "Mantissa"), mantissa),
+	}
+	display = append(display, bitGrid(num, format.bitColor)...)
+	if err != nil {
+		display[0] = paint(errorElement, "Last input was invalid")
+	}
+	return display
+}
@@ -2,7 +2,10 @@ module github.com/geofpwhite/tcalc
 
 go 1.25.3
 
-require fortio.org/terminal v0.63.0
+require (
+	fortio.org/terminal v0.63.0
+	golang.org/x/text v0.32.0
+)
 
 require (
 	fortio.org/log v1.18.3 // indirect
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// helpOverlay is the F1 reference covering the whole screen.
+type helpOverlay struct {
+	open   bool
+	scroll int // first line shown
+}
+
+// helpLines builds the reference from the registries used to run keys, commands and
+// expressions so that it can't go out of date.
+func helpLines() []string {
+	var lines []string
+	section := func(title string) {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, paint(accentElement, title))
+	}
+	entry := func(form, help string) {
+		lines = append(lines, "  "+form+strings.Repeat(" ", max(1, 24-len([]rune(form))))+help)
+	}
+	section("Keys")
+	for _, b := range keyBindings {
+		if b.name != "" {
+			entry(b.name, b.help)
+		}
+	}
+	section("Operators")
+	lines = append(lines, "  "+calculator.Precedence)
+	for _, op := range calculator.Operators() {
+		entry(op.Form, op.Help)
+	}
+	section("Literals and references")
+	for _, literal := range calculator.Literals {
+		entry(literal.Form, literal.Help)
+	}
+	section("Functions")
+	for _, name := range slices.Sorted(maps.Keys(calculator.Functions)) {
+		f := calculator.Functions[name]
+		entry(f.Signature(), f.Help)
+	}
+	section("Commands")
+	for _, name := range commandNames() {
+		cmd := commands[name]
+		entry(cmd.usage(), cmd.help)
+	}
+	return lines
+}
+
+func (c *config) toggleHelp() {
+	c.help = helpOverlay{open: !c.help.open}
+}
+
+func (c *config) helpRows() int {
+	return max(1, c.AP.H-1) // the last row holds the key hints
+}
+
+// scrollHelp moves by delta lines, keeping the last page full.
+func (c *config) scrollHelp(delta int) {
+	c.help.scroll = max(0, min(c.help.scroll+delta, len(helpLines())-c.helpRows()))
+}
+
+func (c *config) handleHelpKey(key string) {
+	switch key {
+	case "\x1b", "q", "?", "\x1bOP", "\x1b[11~": // escape, F1
+		c.help.open = false
+	case "\x1b[A", "k":
+		c.scrollHelp(-1)
+	case "\x1b[B", "j":
+		c.scrollHelp(1)
+	case "\x1b[5~", "\x7f":
+		c.scrollHelp(-c.helpRows())
+	case "\x1b[6~", " ":
+		c.scrollHelp(c.helpRows())
+	case "\x1b[H", "g":
+		c.help.scroll = 0
+	case "\x1b[F", "G":
+		c.scrollHelp(len(helpLines()))
+	}
+}
+
+func (c *config) drawHelp() {
+	lines := helpLines()
+	rows := c.helpRows()
+	c.help.scroll = max(0, min(c.help.scroll, len(lines)-rows))
+	for i, line := range lines[c.help.scroll:min(len(lines), c.help.scroll+rows)] {
+		c.AP.WriteAtStr(0, i, clip(line, c.AP.W))
+	}
+	hint := "↑↓ PgUp PgDn Home End scroll, Esc closes"
+	if len(lines) > rows {
+		hint += ", lines " + strconv.Itoa(c.help.scroll+1) + "-" +
+			strconv.Itoa(min(len(lines), c.help.scroll+rows)) + " of " + strconv.Itoa(len(lines))
+	}
+	c.AP.WriteAtStr(0, c.AP.H-1, paint(dimElement, clip(hint, c.AP.W)))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fortio.org/terminal/ansipixels/tcolor"
+)
+
+// historyCapacity is how many entries fit in the history pane, each takes two rows.
+func (c *config) historyCapacity() int {
+	return max(1, c.layout.history.h/2-1)
+}
+
+// visibleHistory returns the indexes of the first and last entries shown in the history
+// pane, historyScroll entries are hidden below the last one.
+func (c *config) visibleHistory() (first, last int) {
+	c.historyScroll = max(0, min(c.historyScroll, len(c.history)-c.historyCapacity()))
+	last = len(c.history) - 1 - c.historyScroll
+	first = max(0, last-c.historyCapacity()+1)
+	return first, last
+}
+
+// historyRow is the row the entry at index i is drawn on, given the last visible entry.
+func (c *config) historyRow(i, last int) int {
+	pane := c.layout.history
+	return pane.y + pane.h - (last+1-i)*2
+}
+
+func (c *config) scrollHistory(entries int) {
+	c.historyScroll += entries
+	c.visibleHistory() // clamps
+}
+
+// showRecord scrolls the history pane so that the entry at index i is visible.
+func (c *config) showRecord(i int) {
+	first, last := c.visibleHistory()
+	switch {
+	case i > last:
+		c.historyScroll = len(c.history) - 1 - i
+	case i < first:
+		c.historyScroll = len(c.history) - i - c.historyCapacity()
+	}
+	c.visibleHistory()
+}
+
+// historyEntryAt returns the index of the entry drawn at the 1-based mouse coordinates,
+// clicking on the line below an entry selects it too.
+func (c *config) historyEntryAt(x, y int) (int, bool) {
+	pane := c.layout.history
+	if pane.empty() || !pane.contains(x-1, y-1) {
+		return 0, false
+	}
+	first, last := c.visibleHistory()
+	i := last + 1 - (pane.y+pane.h-(y-1)+1)/2
+	return i, i >= first && i <= last
+}
+
+// historyLabel prefixes the entry with the number expressions can refer to it with and
+// shows how it was grouped rather than how it was typed.
+func historyLabel(record historyRecord) string {
+	line := cmp.Or(record.parsed, record.evaluated) + ": " + record.result()
+	if record.id == 0 {
+		return line
+	}
+	return "$" + strconv.Itoa(record.id) + " " + line
+}
+
+// loadRecord puts the expression of the entry at index i in the input line.
+func (c *config) loadRecord(i int) {
+	c.curRecord = i
+	c.input = c.history[i].evaluated
+	c.index = len(c.input)
+}
+
+// recallRecord makes the value of the entry at index i the current answer.
+func (c *config) recallRecord(i int) {
+	record := c.history[i]
+	if !c.state.Recall(record.id) {
+		c.state.Ans, c.state.Real = record.finalValue, nil
+	}
+	c.clicked = false
+	c.message = "recalled " + historyLabel(record)
+}
+
+// deleteRecord removes the numbered entry id from the history, other entries keep their number.
+func (c *config) deleteRecord(id int) bool {
+	for i, record := range c.history {
+		if record.id == id && id != 0 {
+			c.history = append(c.history[:i], c.history[i+1:]...)
+			c.state.DeleteResult(id)
+			c.curRecord = -1
+			c.visibleHistory()
+			return true
+		}
+	}
+	return false
+}
+
+func (c *config) handleMouse() {
+	if c.help.open {
+		switch {
+		case c.AP.MouseWheelUp():
+			c.scrollHelp(-3)
+		case c.AP.MouseWheelDown():
+			c.scrollHelp(3)
+		}
+		return
+	}
+	switch {
+	case c.AP.MouseWheelUp():
+		c.scrollHistory(1)
+	case c.AP.MouseWheelDown():
+		c.scrollHistory(-1)
+	case c.AP.LeftClick() && c.AP.MouseRelease():
+		c.handleClick(c.AP.Mx, c.AP.My)
+	case c.AP.RightClick() && c.AP.MouseRelease():
+		if i, ok := c.historyEntryAt(c.AP.Mx, c.AP.My); ok {
+			c.recallRecord(i)
+		}
+	}
+}
+
+func (c *config) handleClick(x, y int) {
+	if i, ok := c.historyEntryAt(x, y); ok {
+		c.loadRecord(i)
+		return
+	}
+	if name, ok := c.variableAt(x, y); ok {
+		c.insert(name)
+		return
+	}
+	if c.layout.tooSmall || c.view == compareView || c.view == explainView || c.view == tableView {
+		return // the grids of these views are not Ans
+	}
+	if slices.Contains(validClickXs, x) && y < c.AP.H-2 && y >= c.AP.H-6 {
+		bit := c.determineBitFromXY(x, c.AP.H-2-y)
+		c.clicked = true
+		c.state.Ans ^= 1 << bit
+	}
+}
+
+func (c *config) DrawHistory() {
+	pane := c.layout.history
+	if pane.empty() {
+		return
+	}
+	right := pane.x + pane.w
+	if c.layout.sideHistory {
+		for i := range c.AP.H {
+			c.AP.WriteAtStr(pane.x-1, i, "⏐")
+		}
+		c.AP.WriteAtStr(pane.x, pane.y+pane.h-1, strings.Repeat("⎯", pane.w))
+	}
+	first, last := c.visibleHistory()
+	if first > 0 {
+		c.AP.WriteAtStr(pane.x+1, pane.y, paint(dimElement, "↑ "+strconv.Itoa(first)+" more"))
+	}
+	if last < len(c.history)-1 {
+		c.AP.WriteAtStr(pane.x+1, pane.y+pane.h-1,
+			paint(dimElement, "↓ "+strconv.Itoa(len(c.history)-1-last)+" more"))
+	}
+	width := pane.w
+	for i := first; i <= last; i++ {
+		// keep the end of long entries, the result, counting runes as they are columns
+		line := []rune(historyLabel(c.history[i]))
+		if len(line) > width {
+			line = append([]rune{'…'}, line[len(line)-width+1:]...)
+		}
+		row := c.historyRow(i, last)
+		rule := []rune(strings.Repeat("⎯", len(line)))
+		if c.curRecord == i {
+			rule = []rune(strings.Repeat("⎯", width))
+			c.AP.WriteAtStr(right-len(rule), row+1, colors[selectionElement]+string(rule))
+		}
+		if c.curRecord != i-1 && row > pane.y {
+			c.AP.WriteAtStr(right-len(rule), row-1, string(rule)+tcolor.Reset)
+		}
+		c.AP.WriteAtStr(right-len(line), row, tcolor.Reset+string(line))
+	}
+}
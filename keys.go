@@ -0,0 +1,106 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// keyBinding is a key of the main input line, the help overlay lists them.
+type keyBinding struct {
+	name string   // how help shows the key
+	keys []string // what terminals send, nil for the mouse and for keys handled elsewhere
+	help string
+	run  func(c *config)
+}
+
+var keyBindings []keyBinding
+
+func init() {
+	keyBindings = []keyBinding{
+		{name: "Enter", keys: []string{"\r", "\n"}, help: "evaluate, repeat the last operation when empty", run: (*config).handleEnter},
+		{name: "Backspace", keys: []string{"\x7f"}, help: "delete before the cursor", run: func(c *config) {
+			before, after := c.input[:max(0, c.index-1)], c.input[c.index:]
+			c.input = before + after
+			c.index = max(c.index-1, 0)
+		}},
+		{name: "Delete", keys: []string{"\x1b[3~"}, help: "delete at the cursor", run: func(c *config) {
+			before, after := c.input[:c.index], c.input[min(len(c.input), c.index+1):]
+			c.input = before + after
+		}},
+		{name: "Left Right", keys: []string{"\x1b[D"}, help: "move the cursor", run: func(c *config) {
+			c.index = max(c.index-1, 0)
+		}},
+		{keys: []string{"\x1b[C"}, run: func(c *config) { c.index = min(c.index+1, len(c.input)) }},
+		{name: "Home End", keys: []string{"\x1b[H"}, help: "go to the start or the end of the line", run: func(c *config) {
+			c.index = 0
+		}},
+		{keys: []string{"\x1b[F"}, run: func(c *config) { c.index = len(c.input) }},
+		{name: "Up Down", keys: []string{"\x1b[A"}, help: "walk through history", run: (*config).historyUp},
+		{keys: []string{"\x1b[B"}, run: (*config).historyDown},
+		{name: "PgUp PgDn", keys: []string{"\x1b[5~"}, help: "scroll the history pane", run: func(c *config) {
+			c.scrollHistory(c.historyCapacity())
+		}},
+		{keys: []string{"\x1b[6~"}, run: func(c *config) { c.scrollHistory(-c.historyCapacity()) }},
+		{name: "Shift+Del", keys: []string{"\x1b[3;2~"}, help: "delete the selected history entry", run: func(c *config) {
+			if c.curRecord > 0 {
+				c.deleteRecord(c.history[c.curRecord].id)
+			}
+		}},
+		{name: "Tab", keys: []string{"\t"}, help: "complete a :command, otherwise switch the result view", run: func(c *config) {
+			if strings.HasPrefix(c.input, ":") {
+				c.completeCommand()
+			} else {
+				c.view = (c.view + 1) % numViews
+			}
+		}},
+		{name: "F1 ?", keys: []string{"\x1bOP", "\x1b[11~"}, help: "this help, ? on an empty line", run: (*config).toggleHelp},
+		{name: "F2", keys: []string{"\x1bOQ", "\x1b[12~"}, help: "show the variables: enter, e, d, s, b", run: (*config).toggleVariables},
+		{name: "Ctrl+P", keys: []string{"\x10"}, help: "search the :commands", run: (*config).togglePalette},
+		{name: "Ctrl+Y", keys: []string{"\x19"}, help: "copy the result in the :copybase base", run: func(c *config) {
+			c.copyResult(c.copyBase)
+		}},
+		{name: "Alt+D X B O", help: "copy the result in decimal, hex, binary or octal"},
+		{name: "Alt+Y", keys: []string{"\x1by"}, help: "copy the history entry", run: (*config).copyEntry},
+		{name: "Ctrl+C", help: "quit, printing the result"},
+		{name: "Click", help: "flip a bit, load a history entry or insert a variable"},
+		{name: "Right click", help: "recall the value of a history entry as the result"},
+		{name: "Wheel", help: "scroll the history pane"},
+	}
+}
+
+// keyBindingFor finds what handles the key sent by the terminal.
+func keyBindingFor(key string) (keyBinding, bool) {
+	for _, b := range keyBindings {
+		if b.run != nil && slices.Contains(b.keys, key) {
+			return b, true
+		}
+	}
+	return keyBinding{}, false
+}
+
+func (c *config) historyUp() {
+	if len(c.history) <= 1 {
+		return
+	}
+	switch c.curRecord {
+	case -1:
+		c.curRecord += len(c.history)
+	case 0:
+		c.curRecord += len(c.history) - 1
+	default:
+		c.curRecord--
+	}
+	c.input = c.history[c.curRecord].evaluated
+	c.index = len(c.input)
+	c.showRecord(c.curRecord)
+}
+
+func (c *config) historyDown() {
+	if len(c.history) <= 1 {
+		return
+	}
+	c.curRecord = (c.curRecord + 1) % len(c.history)
+	c.input = c.history[c.curRecord].evaluated
+	c.index = len(c.input)
+	c.showRecord(c.curRecord)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"fortio.org/terminal/ansipixels/tcolor"
+)
+
+// rect is an area of the screen in 0-based cells, panels that don't fit get an empty one.
+type rect struct {
+	x, y, w, h int
+}
+
+func (r rect) empty() bool {
+	return r.w <= 0 || r.h <= 0
+}
+
+func (r rect) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.w && y >= r.y && y < r.y+r.h
+}
+
+const (
+	minWidth  = 40 // the bit grid takes 39 columns
+	minHeight = 8  // the bit grid, the line above it and the input line with its rule
+	// sideHistoryWidth is the terminal width from which history gets its own column.
+	sideHistoryWidth = 77
+	// minHistoryRows is the least space worth stacking history above the results for.
+	minHistoryRows = 6
+)
+
+// takeTop splits the first n rows (at most) off r.
+func (r *rect) takeTop(n int) rect {
+	top := rect{r.x, r.y, r.w, min(n, r.h)}
+	r.y += top.h
+	r.h -= top.h
+	return top
+}
+
+// layout places the panels for a terminal size, it is recomputed every frame and on resize.
+type layout struct {
+	tooSmall     bool
+	instructions rect
+	results      rect // bottom anchored, the top lines are cut when short of rows
+	input        rect
+	watch        rect // pinned expressions, above history
+	variables    rect // between the watches and history
+	history      rect
+	sideHistory  bool // history is right of the results rather than above them
+}
+
+// panelSizes are the lines wanted by the panels whose content varies.
+type panelSizes struct {
+	results, watch, variables int
+}
+
+func computeLayout(w, h int, sizes panelSizes) layout {
+	l := layout{tooSmall: w < minWidth || h < minHeight}
+	mainWidth := w
+	if w >= sideHistoryWidth {
+		mainWidth = w / 2
+		column := rect{mainWidth + 1, 0, w - mainWidth - 1, h}
+		l.watch = column.takeTop(min(sizes.watch, h/3))
+		l.variables = column.takeTop(min(sizes.variables, h/3))
+		l.history = column
+		l.sideHistory = true
+	}
+	l.input = rect{0, h - 2, mainWidth, 2}
+	top := max(0, h-3-sizes.results)
+	l.results = rect{0, top, mainWidth, h - 3 - top}
+	free := top
+	if free >= len(instructions) {
+		l.instructions = rect{0, 0, mainWidth, len(instructions)}
+		free -= len(instructions)
+	}
+	if !l.sideHistory {
+		column := rect{0, l.instructions.h, mainWidth, free}
+		l.watch = column.takeTop(sizes.watch)
+		l.variables = column.takeTop(sizes.variables)
+		if column.h >= minHistoryRows {
+			l.history = column
+		}
+	}
+	return l
+}
+
+// relayout updates c.layout for the current terminal size and result panel.
+func (c *config) relayout() []string {
+	strings := c.resultStrings()
+	c.layout = computeLayout(c.AP.W, c.AP.H, panelSizes{
+		results:   len(strings),
+		watch:     c.watchLines(),
+		variables: c.variablesLines(),
+	})
+	return strings
+}
+
+func (c *config) draw() {
+	results := c.relayout()
+	c.AP.ClearScreen()
+	if c.layout.tooSmall {
+		c.drawTooSmall()
+		return
+	}
+	if c.help.open {
+		c.drawHelp()
+		return
+	}
+	for i, str := range instructions[:c.layout.instructions.h] {
+		c.AP.WriteAtStr(0, i, clip(str, c.layout.instructions.w))
+	}
+	results = results[len(results)-c.layout.results.h:]
+	y := c.layout.results.y
+	for i, str := range results {
+		c.AP.WriteAtStr(0, y+i, clip(str, c.layout.results.w))
+	}
+	if c.message != "" && c.state.Err == nil {
+		c.AP.WriteAtStr(0, y, clip(c.message, c.layout.results.w))
+	}
+	c.AP.WriteAtStr(0, c.layout.input.y, highlightInput(c.input))
+	c.AP.WriteAtStr(0, c.layout.input.y+1, strings.Repeat("⎯", c.layout.input.w-1))
+	c.drawWatches()
+	c.drawVariables()
+	c.DrawHistory()
+	c.drawPalette()
+	c.AP.MoveCursor(c.index, c.layout.input.y)
+}
+
+func (c *config) drawTooSmall() {
+	lines := []string{
+		"Terminal too small",
+		strconv.Itoa(c.AP.W) + "x" + strconv.Itoa(c.AP.H) + ", need " +
+			strconv.Itoa(minWidth) + "x" + strconv.Itoa(minHeight),
+	}
+	for i, line := range lines {
+		c.AP.WriteAtStr(max(0, (c.AP.W-len(line))/2), c.AP.H/2-1+i, clip(line, c.AP.W))
+	}
+}
+
+// clip cuts s to width runes, not counting the escape sequences that color it. A cut
+// colored line ends with a reset so the color doesn't spill over what is drawn next.
+func clip(s string, width int) string {
+	const (
+		text = iota
+		escape
+		csi // in a control sequence such as a color, up to its final byte
+	)
+	state, visible, colored := text, 0, false
+	for i, r := range s {
+		switch {
+		case r == '\x1b':
+			state, colored = escape, true
+		case state == escape && r == '[':
+			state = csi
+		case state == escape || state == csi && r >= 0x40 && r <= 0x7e:
+			state = text
+		case state == csi:
+		case visible >= width:
+			if colored {
+				return s[:i] + tcolor.Reset
+			}
+			return s[:i]
+		default:
+			visible++
+		}
+	}
+	return s
+}
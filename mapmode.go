@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+const mapUsage = `usage: tcalc map [flags] 'expression' < values
+
+Evaluates the expression for every line of the standard input and prints one result
+per line. A line of a single value binds x, several values separated by commas or
+blanks bind x1, x2… (x being x1) or the names of the -header line. Lines that fail,
+including the ones with fewer values than the expression uses, are reported on the
+standard error and skipped.
+`
+
+// runMap is the map mode, it returns the exit status.
+func runMap(s *calculator.State, args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("map", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = func() {
+		fmt.Fprint(errOut, mapUsage)
+		fs.PrintDefaults()
+	}
+	baseName := fs.String("base", "dec", "`base` of the results: "+strings.Join(baseNames(), ", "))
+	header := fs.Bool("header", false, "the first line names the columns")
+	fs.IntVar(&s.Width, "width", 0, "wrap the results to that many `bits`")
+	fs.BoolVar(&s.Unsigned, "unsigned", false, "don't sign extend results narrower than 64 bits")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	base, ok := copyBaseNames[*baseName]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return 2
+	}
+	node, err := s.AST(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 2
+	}
+	m := mapper{state: s, expr: fs.Arg(0), needs: columnsUsed(node)}
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	failed := 0
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		fields := splitFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if *header && m.columns == nil {
+			m.columns = fields
+			continue
+		}
+		value, err := m.eval(fields)
+		if err != nil {
+			failed++
+			fmt.Fprintf(errOut, "line %d: %v\n", line, err)
+			continue
+		}
+		fmt.Fprintln(w, formatInt(value, base))
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// splitFields cuts a line at commas when it has some, at blanks otherwise.
+func splitFields(line string) []string {
+	if !strings.Contains(line, ",") {
+		return strings.Fields(line)
+	}
+	fields := strings.Split(line, ",")
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return fields
+}
+
+// parseValue reads a value of the input: an integer or a constant expression such as 'A'.
+func parseValue(s *calculator.State, field string) (int64, error) {
+	if value, err := strconv.ParseInt(field, 0, 64); err == nil {
+		return value, nil
+	}
+	program, err := s.Compile(field)
+	if err == nil && len(program.Vars) > 0 {
+		err = errors.New("not a value")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", field, err)
+	}
+	return program.Run(nil)
+}
+
+// mapper evaluates the expression of the map mode, compiled once the columns are known
+// unless it needs real arithmetic.
+type mapper struct {
+	state    *calculator.State
+	expr     string
+	columns  []string // names from the header line
+	needs    int      // columns the expression uses without a header, 3 for x1 + x3
+	program  calculator.Program
+	compiled bool
+	slow     bool // the expression didn't compile, it is evaluated on every line
+	values   []int64
+}
+
+// columnsUsed returns the highest n of the x1, x2… the expression refers to.
+func columnsUsed(node calculator.Node) int {
+	used := 0
+	if node.Kind == calculator.Identifier && strings.HasPrefix(node.Value, "x") {
+		if n, err := strconv.Atoi(node.Value[1:]); err == nil && n > 0 {
+			used = n
+		}
+	}
+	for _, child := range node.Children {
+		used = max(used, columnsUsed(child))
+	}
+	return used
+}
+
+func (m *mapper) names(n int) []string {
+	if m.columns != nil {
+		return append([]string{"x"}, m.columns...)
+	}
+	names := []string{"x"}
+	for i := range n {
+		names = append(names, "x"+strconv.Itoa(i+1))
+	}
+	return names
+}
+
+func (m *mapper) eval(fields []string) (int64, error) {
+	if m.columns != nil && len(fields) != len(m.columns) {
+		return 0, fmt.Errorf("%d values for %d columns", len(fields), len(m.columns))
+	}
+	if m.columns == nil && len(fields) < m.needs {
+		// the columns of an earlier line are still set, they must not stand in
+		return 0, fmt.Errorf("%d values, the expression uses x%d", len(fields), m.needs)
+	}
+	// x is the first column, unless a column is named x
+	names, values := m.names(len(fields)), make([]int64, 0, len(fields)+1)
+	for i, field := range fields {
+		value, err := parseValue(m.state, field)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			values = append(values, value)
+		}
+		values = append(values, value)
+	}
+	for i, name := range names {
+		m.state.Variables[name] = values[i]
+	}
+	if !m.compiled && !m.slow {
+		program, err := m.state.Compile(m.expr)
+		if err != nil {
+			// sqrt(x), f32(x)… need real arithmetic, the expression is evaluated on every line
+			if _, err := m.state.Evaluate(m.expr); err != nil {
+				return 0, err
+			}
+			m.slow = true
+		}
+		m.program, m.compiled = program, err == nil
+		m.values = make([]int64, len(program.Vars))
+	}
+	if m.slow {
+		return m.state.Evaluate(m.expr)
+	}
+	for i, name := range m.program.Vars {
+		m.values[i] = m.state.Variables[name]
+	}
+	return m.program.Run(m.values)
+}
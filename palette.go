@@ -0,0 +1,106 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"fortio.org/terminal/ansipixels/tcolor"
+)
+
+// palette is the Ctrl+P command search drawn over the results.
+type palette struct {
+	open     bool
+	query    string
+	selected int
+}
+
+// fuzzyMatch reports whether the letters of query appear in order in s.
+func fuzzyMatch(query, s string) bool {
+	for _, r := range query {
+		i := strings.IndexRune(s, r)
+		if i == -1 {
+			return false
+		}
+		s = s[i+1:]
+	}
+	return true
+}
+
+// paletteMatches are the commands whose name or help matches the query: the names
+// starting with it, then the other matching names, then the matching help.
+func paletteMatches(query string) []command {
+	query = strings.ToLower(query)
+	var prefixed, named, described []command
+	for _, name := range commandNames() {
+		cmd := commands[name]
+		switch {
+		case strings.HasPrefix(name, query):
+			prefixed = append(prefixed, cmd)
+		case fuzzyMatch(query, name):
+			named = append(named, cmd)
+		case fuzzyMatch(query, strings.ToLower(cmd.help)):
+			described = append(described, cmd)
+		}
+	}
+	return slices.Concat(prefixed, named, described)
+}
+
+func (c *config) togglePalette() {
+	c.palette = palette{open: !c.palette.open}
+}
+
+// handlePaletteKey runs the selected command on enter, or starts typing it when it
+// takes arguments.
+func (c *config) handlePaletteKey(key string) {
+	matches := paletteMatches(c.palette.query)
+	switch key {
+	case "\x1b", "\x10": // escape, ctrl+p
+		c.palette.open = false
+	case "\x7f":
+		c.palette.query = c.palette.query[:max(0, len(c.palette.query)-1)]
+		c.palette.selected = 0
+	case "\x1b[A": // up
+		c.palette.selected = max(0, c.palette.selected-1)
+	case "\x1b[B": // down
+		c.palette.selected = min(len(matches)-1, c.palette.selected+1)
+	case "\r", "\n":
+		c.palette.open = false
+		if len(matches) == 0 {
+			return
+		}
+		cmd := matches[min(c.palette.selected, len(matches)-1)]
+		if cmd.args == "" {
+			c.input = ":" + cmd.name
+			c.handleEnter()
+			return
+		}
+		c.input = ":" + cmd.name + " "
+		c.index = len(c.input)
+	default:
+		if key[0] >= ' ' && !strings.HasPrefix(key, "\x1b") {
+			c.palette.query += key
+			c.palette.selected = 0
+		}
+	}
+}
+
+// drawPalette draws the search and its matches from the top of the results down.
+func (c *config) drawPalette() {
+	if !c.palette.open || c.layout.tooSmall {
+		return
+	}
+	w := c.layout.input.w
+	rows := min(c.layout.input.y-1, 10)
+	pad := func(line string) string { return line + strings.Repeat(" ", max(0, w-len(line))) }
+	c.AP.WriteAtStr(0, 0, pad(clip("Command (Esc closes): "+c.palette.query, w)))
+	for i, cmd := range paletteMatches(c.palette.query) {
+		if i+1 >= rows {
+			break
+		}
+		line := pad(clip(cmd.usage()+"  "+cmd.help, w))
+		if i == c.palette.selected {
+			line = paint(selectionElement, tcolor.Inverse+line)
+		}
+		c.AP.WriteAtStr(0, i+1, line)
+	}
+}
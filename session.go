@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+func (c *config) clearHistory() {
+	c.history = []historyRecord{{evaluated: "0"}}
+	c.state.History = nil
+	c.curRecord, c.historyScroll = -1, 0
+}
+
+func (c *config) clearVariables() {
+	for name := range c.state.Variables {
+		c.state.DeleteVariable(name)
+	}
+	c.refreshWatches()
+}
+
+// settings change how expressions evaluate, scripts set them again before the history
+// entries that were evaluated with other ones.
+type settings struct {
+	mode     calculator.Mode
+	width    int
+	unsigned bool
+}
+
+func (c *config) settings() settings {
+	return settings{c.state.Mode, cmp.Or(c.state.Width, 64), c.state.Unsigned}
+}
+
+// commands are the lines of a script going from the settings from to s, all of them
+// when from is nil.
+func (s settings) commands(from *settings) []string {
+	var lines []string
+	if from == nil || s.mode != from.mode {
+		lines = append(lines, ":mode "+s.mode.String())
+	}
+	if from == nil || s.width != from.width {
+		lines = append(lines, ":width "+strconv.Itoa(s.width))
+	}
+	if from == nil || s.unsigned != from.unsigned {
+		lines = append(lines, ":signedness "+map[bool]string{false: "signed", true: "unsigned"}[s.unsigned])
+	}
+	return lines
+}
+
+// historyScript writes the history entries as lines to evaluate again, each after the
+// settings it was evaluated with, and returns the settings of the last one if any. Results are
+// numbered from 1 in a fresh session whatever was deleted or cleared before, so the
+// references to results are renumbered to match. comments adds the number, result and
+// time of every entry.
+func (c *config) historyScript(comments bool) ([]string, *settings) {
+	var lines []string
+	ids := map[int]int{}
+	for i, record := range c.history[1:] {
+		ids[record.id] = i + 1
+	}
+	var last *settings
+	for i, record := range c.history[1:] {
+		lines = append(lines, record.settings.commands(last)...)
+		last = &record.settings
+		if comments {
+			lines = append(lines, "# $"+strconv.Itoa(i+1)+" = "+record.result()+" at "+record.time.Format(time.RFC3339))
+		}
+		lines = append(lines, c.renumber(record.evaluated, ids))
+	}
+	return lines, last
+}
+
+// sessionScript is what :save writes: the history entries to evaluate again, the
+// settings as commands, then the final variables and the watches.
+func (c *config) sessionScript() []string {
+	s := c.state
+	lines := []string{"# tcalc session", ":q " + c.qFormat.String()}
+	if s.BigEndianStrings {
+		lines = append(lines, ":strings be")
+	}
+	history, last := c.historyScript(false)
+	lines = append(lines, history...)
+	lines = append(lines, c.settings().commands(last)...)
+	fractions := false
+	for _, name := range c.sortedVariables() {
+		value := strconv.FormatInt(s.Variables[name], 10)
+		if exact, ok := s.Reals[name]; ok {
+			value = exact.RatString()
+			fractions = fractions || !exact.IsInt()
+		}
+		lines = append(lines, ":set "+name+" "+value)
+	}
+	if fractions && s.Mode != calculator.RationalMode {
+		// fractions only evaluate exactly in the rational mode
+		lines = slices.Insert(lines, len(lines)-len(s.Variables), ":mode rational")
+		lines = append(lines, ":mode "+s.Mode.String())
+	}
+	for _, w := range c.watches {
+		lines = append(lines, ":watch "+w.expr)
+	}
+	return lines
+}
+
+func (c *config) saveSession(path string) error {
+	err := os.WriteFile(path, []byte(strings.Join(c.sessionScript(), "\n")+"\n"), 0o600)
+	if err == nil {
+		c.message = "saved the session to " + path
+	}
+	return err
+}
+
+// loadSession replays a script: lines starting with ':' are commands, the others are
+// evaluated like typed input and '#' starts a comment line.
+func (c *config) loadSession(path string) error {
+	if c.loading {
+		return errors.New("scripts can't :load other scripts")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c.loading = true
+	defer func() { c.loading = false }()
+	var lines, failed int
+	var firstErr error
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines++
+		if strings.HasPrefix(line, ":") {
+			err = c.execCommand(line)
+		} else {
+			c.input, c.index = line, len(line)
+			c.handleEnter()
+			err = c.state.Err
+		}
+		if err != nil {
+			failed++
+			firstErr = cmp.Or(firstErr, errors.New(line+": "+err.Error()))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	c.refreshWatches()
+	c.message = "loaded " + strconv.Itoa(lines) + " lines from " + path
+	if failed > 0 {
+		return errors.New(c.message + ", " + strconv.Itoa(failed) + " failed, first " + firstErr.Error())
+	}
+	return nil
+}
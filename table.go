@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// maxTableRows bounds the combinations of a sweep.
+const maxTableRows = 1 << 16
+
+// sweep is an expression evaluated over every combination of ranges of its variables,
+// as in "a ^ b for a in 0..1, b in 0..1".
+type sweep struct {
+	expr   string
+	vars   []string
+	ranges [][2]int64 // inclusive bounds
+}
+
+func parseSweep(s *calculator.State, spec string) (sweep, error) {
+	i := strings.LastIndex(spec, " for ")
+	if i == -1 {
+		return sweep{}, errors.New("usage: expression for x in 0..15[, y in a..b]")
+	}
+	sw := sweep{expr: strings.TrimSpace(spec[:i])}
+	rows := int64(1)
+	for _, clause := range strings.Split(spec[i+len(" for "):], ",") {
+		name, bounds, ok := strings.Cut(strings.TrimSpace(clause), " in ")
+		low, high, ok2 := strings.Cut(bounds, "..")
+		if !ok || !ok2 {
+			return sweep{}, errors.New("ranges are written x in 0..15, not " + strings.TrimSpace(clause))
+		}
+		name = strings.TrimSpace(name)
+		if node, err := s.AST(name); err != nil || node.Kind != calculator.Identifier {
+			return sweep{}, errors.New(name + " isn't a variable name")
+		}
+		if slices.Contains(sw.vars, name) {
+			return sweep{}, errors.New(name + " is swept twice")
+		}
+		lo, err := parseValue(s, strings.TrimSpace(low))
+		if err != nil {
+			return sweep{}, err
+		}
+		hi, err := parseValue(s, strings.TrimSpace(high))
+		if err != nil {
+			return sweep{}, err
+		}
+		if lo > hi {
+			return sweep{}, fmt.Errorf("empty range %d..%d", lo, hi)
+		}
+		span := uint64(hi) - uint64(lo) //nolint:gosec // the distance doesn't fit in an int64 for wide ranges
+		if rows *= int64(min(span, maxTableRows)) + 1; rows > maxTableRows {
+			return sweep{}, fmt.Errorf("more than %d rows", maxTableRows)
+		}
+		sw.vars = append(sw.vars, name)
+		sw.ranges = append(sw.ranges, [2]int64{lo, hi})
+	}
+	if _, err := s.AST(sw.expr); err != nil {
+		return sweep{}, err
+	}
+	return sw, nil
+}
+
+// tableRow is one combination of the variables with the value of the expression.
+type tableRow struct {
+	values []int64
+	result int64
+	err    error
+}
+
+// evaluate runs the sweep in a copy of s so that the variables it sets don't leak.
+func (sw sweep) evaluate(s *calculator.State) []tableRow {
+	scratch := *s
+	scratch.Variables, scratch.Reals = maps.Clone(s.Variables), maps.Clone(s.Reals)
+	program, compileErr := scratch.Compile(sw.expr)
+	values := make([]int64, len(program.Vars))
+	var rows []tableRow
+	current := make([]int64, len(sw.vars))
+	for i, r := range sw.ranges {
+		current[i] = r[0]
+	}
+	for {
+		row := tableRow{values: append([]int64(nil), current...)}
+		for i, name := range sw.vars {
+			scratch.Variables[name] = current[i]
+			delete(scratch.Reals, name)
+		}
+		if compileErr == nil {
+			for i, name := range program.Vars {
+				values[i] = scratch.Variables[name]
+			}
+			row.result, row.err = program.Run(values)
+		} else {
+			row.result, row.err = scratch.Evaluate(sw.expr) // needs real arithmetic
+		}
+		rows = append(rows, row)
+		// the last variable changes fastest, like the digits of a counter
+		i := len(current) - 1
+		for ; i >= 0 && current[i] == sw.ranges[i][1]; i-- {
+			current[i] = sw.ranges[i][0]
+		}
+		if i < 0 {
+			return rows
+		}
+		current[i]++
+	}
+}
+
+// cells writes the header and the rows of the table: the variables in decimal, the
+// result in decimal, hex and binary padded to the widest non-negative result.
+func (sw sweep) cells(rows []tableRow) [][]string {
+	width := 1
+	for _, row := range rows {
+		if row.err == nil && row.result >= 0 {
+			width = max(width, bits.Len64(uint64(row.result)))
+		}
+	}
+	table := [][]string{append(append([]string(nil), sw.vars...), sw.expr, "hex", "bin")}
+	for _, row := range rows {
+		line := make([]string, 0, len(table[0]))
+		for _, value := range row.values {
+			line = append(line, strconv.FormatInt(value, 10))
+		}
+		if row.err != nil {
+			line = append(line, "error: "+row.err.Error(), "", "")
+		} else {
+			binary := strconv.FormatUint(uint64(row.result), 2) //nolint:gosec // bit pattern
+			line = append(line, strconv.FormatInt(row.result, 10), formatInt(row.result, 16),
+				"0b"+strings.Repeat("0", max(0, width-len(binary)))+binary)
+		}
+		table = append(table, line)
+	}
+	return table
+}
+
+// tableFormats render the cells of a table, the first row being the header.
+var tableFormats = map[string]func(cells [][]string) []string{
+	"text": func(cells [][]string) []string {
+		widths := make([]int, len(cells[0]))
+		for _, row := range cells {
+			for i, cell := range row {
+				widths[i] = max(widths[i], len([]rune(cell)))
+			}
+		}
+		lines := make([]string, len(cells))
+		for i, row := range cells {
+			padded := make([]string, len(row))
+			for j, cell := range row {
+				padded[j] = cell + strings.Repeat(" ", widths[j]-len([]rune(cell)))
+			}
+			lines[i] = strings.TrimRight(strings.Join(padded, "  "), " ")
+		}
+		return lines
+	},
+	"csv": func(cells [][]string) []string {
+		lines := make([]string, len(cells))
+		for i, row := range cells {
+			quoted := make([]string, len(row))
+			for j, cell := range row {
+				if strings.ContainsAny(cell, ",\"") {
+					cell = `"` + strings.ReplaceAll(cell, `"`, `""`) + `"`
+				}
+				quoted[j] = cell
+			}
+			lines[i] = strings.Join(quoted, ",")
+		}
+		return lines
+	},
+	"markdown": func(cells [][]string) []string {
+		line := func(row []string) string {
+			escaped := make([]string, len(row))
+			for i, cell := range row {
+				escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			return "| " + strings.Join(escaped, " | ") + " |"
+		}
+		lines := []string{line(cells[0]), "|" + strings.Repeat(" --- |", len(cells[0]))}
+		for _, row := range cells[1:] {
+			lines = append(lines, line(row))
+		}
+		return lines
+	},
+}
+
+const tableUsage = `usage: tcalc table [flags] 'expression for x in 0..15[, y in a..b]'
+
+Prints the value of the expression for every combination of the variables.
+`
+
+// runTable is the batch table mode, it returns the exit status.
+func runTable(s *calculator.State, args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("table", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = func() {
+		fmt.Fprint(errOut, tableUsage)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "markdown", "output `format`: csv, markdown or text")
+	fs.IntVar(&s.Width, "width", 0, "wrap the results to that many `bits`")
+	fs.BoolVar(&s.Unsigned, "unsigned", false, "don't sign extend results narrower than 64 bits")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	render, ok := tableFormats[*format]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return 2
+	}
+	sw, err := parseSweep(s, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 2
+	}
+	for _, line := range render(sw.cells(sw.evaluate(s))) {
+		fmt.Fprintln(out, line)
+	}
+	return 0
+}
+
+// tablePanel is the :table view.
+type tablePanel struct {
+	sweep   sweep
+	cells   [][]string
+	scroll  int  // first row shown
+	focused bool // keys scroll the table instead of editing the input
+}
+
+func (c *config) showTable(spec string) error {
+	sw, err := parseSweep(c.state, spec)
+	if err != nil {
+		return err
+	}
+	c.table = tablePanel{sweep: sw, cells: sw.cells(sw.evaluate(c.state)), focused: true}
+	c.view = tableView
+	return nil
+}
+
+// tableRows is how many rows of the table fit in the tallest results panel the layout
+// allows, below the title, the header and the exact result of the real modes.
+func (c *config) tableRows() int {
+	room := computeLayout(c.AP.W, c.AP.H, panelSizes{results: c.AP.H}).results.h
+	above := 3
+	if realDisplayString(c.state) != "" {
+		above++
+	}
+	return max(1, room-above)
+}
+
+func (c *config) handleTableKey(key string) {
+	t := &c.table
+	rows := len(t.cells) - 1
+	switch key {
+	case "\x1b", "q", "\r", "\n": // escape
+		t.focused = false
+	case "\x1b[A", "k": // up
+		t.scroll--
+	case "\x1b[B", "j": // down
+		t.scroll++
+	case "\x1b[5~": // page up
+		t.scroll -= c.tableRows()
+	case "\x1b[6~", " ": // page down
+		t.scroll += c.tableRows()
+	case "\x1b[H", "g": // home
+		t.scroll = 0
+	case "\x1b[F", "G": // end
+		t.scroll = rows
+	case "c":
+		c.AP.CopyToClipboard(strings.Join(tableFormats["csv"](t.cells), "\n") + "\n")
+		c.message = "copied the table as CSV"
+	case "m":
+		c.AP.CopyToClipboard(strings.Join(tableFormats["markdown"](t.cells), "\n") + "\n")
+		c.message = "copied the table as Markdown"
+	}
+	t.scroll = max(0, min(t.scroll, rows-c.tableRows()))
+}
+
+func (c *config) tableDisplayStrings() []string {
+	t := c.table
+	if t.cells == nil {
+		return []string{"", "Table: use :table expression for x in 0..15"}
+	}
+	keys := "↑↓ scroll, c copies CSV, m Markdown, Esc leaves"
+	if !t.focused {
+		keys = ":table scrolls again"
+	}
+	lines := tableFormats["text"](t.cells)
+	rows := lines[1:]
+	shown := rows[t.scroll:min(len(rows), t.scroll+c.tableRows())]
+	title := fmt.Sprintf("Table of %s, rows %d-%d of %d (%s)", t.sweep.expr, t.scroll+1, t.scroll+len(shown), len(rows), keys)
+	return append([]string{"", title, paint(accentElement, lines[0])}, shown...)
+}
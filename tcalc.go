@@ -1,15 +1,17 @@
 package main
 
 import (
+	"cmp"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"fortio.org/terminal/ansipixels"
-	"fortio.org/terminal/ansipixels/tcolor"
 	"github.com/geofpwhite/tcalc/calculator"
 )
 
@@ -23,11 +25,45 @@ type config struct {
 	curRecord    int
 	clicked      bool
 	clickedValue int64
+	message      string // feedback from the last command, shown above the results
+	view         view
+	floatWidth   int // 16, 32 or 64 bits for the float view
+	qFormat      calculator.QFormat
+	copyBase     int    // base Ctrl+Y copies the result in
+	pasting      bool   // inside a bracketed paste
+	paste        string // bracketed paste received so far
+	// historyScroll is how many history entries are hidden below the history pane.
+	historyScroll int
+	layout        layout
+	themeName     string
+	theme         themeStyles // the named theme with the changes made by :color
+	colorDepth    colorDepth
+	compare       comparison
+	watches       []watch
+	vars          variablesPanel
+	palette       palette
+	help          helpOverlay
+	explanation   explanation
+	table         tablePanel
+	loading       bool // replaying a script with :load
 }
 
 type historyRecord struct {
+	id         int // number of the calculator.Result, 0 for the initial entry
 	evaluated  string
 	finalValue int64
+	real       string // result in the float or rational mode it was evaluated in, if any
+	operation  string // input that started with an operator, repeated by enter on an empty line
+	parsed     string // evaluated with its grouping spelled out, see calculator.Node.Parenthesized
+	time       time.Time
+	settings   // the ones it was evaluated with, for :save and :export
+}
+
+func (r historyRecord) result() string {
+	if r.real != "" {
+		return r.real
+	}
+	return strconv.Itoa(int(r.finalValue))
 }
 
 var validClickXs = []int{
@@ -41,26 +77,91 @@ var instructions = []string{
 	"POW **  LSHIFT <<   RSHIFT >>",
 	"NOT ~   ASSIGN =",
 	"Click on individual bits to flip them.",
+	"F1 or ? for help, F2 for the variables.",
+	":help lists commands, Ctrl+P finds them.",
 	"up and down arrows to navigate history.",
+	"PgUp/PgDn or wheel scroll it, click loads",
+	"an entry, right click recalls its value.",
+	"ans $$ $-2 ans2 are the last results,",
+	"$n or _n to the result numbered n.",
 	"Press ctrl+c to quit.",
 }
 
 func configure(ap *ansipixels.AnsiPixels) config {
-	return config{ap, calculator.NewState(), "", 0, -1, []historyRecord{{"0", 0}}, -1, false, 0}
+	return config{
+		AP:         ap,
+		state:      calculator.NewState(),
+		bitset:     -1,
+		history:    []historyRecord{{evaluated: "0"}},
+		curRecord:  -1,
+		floatWidth: 32,
+		qFormat:    calculator.QFormat{N: 15},
+		copyBase:   16,
+		themeName:  "dark",
+		theme:      themes["dark"],
+		colorDepth: basicColors,
+		vars:       variablesPanel{base: 16},
+	}
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func main() {
+	var imports stringList
+	flag.Var(&imports, "import", "C header or Go `file` whose integer constants are loaded as variables (repeatable)")
+	themeName := flag.String("theme", "dark", "color `theme`: "+strings.Join(themeNames(), ", ")+
+		" (NO_COLOR in the environment keeps only bold, underline… from it)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), "usage: tcalc [flags]\n"+
+			"       tcalc [flags] map [map flags] 'expression' < values\n"+
+			"       tcalc [flags] table [table flags] 'expression for x in 0..15'\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{}))
+	slog.SetDefault(log)
 	ap := ansipixels.NewAnsiPixels(30)
 	c := configure(ap)
+	c.colorDepth = detectColorDepth(ap.ColorMode)
+	if err := c.setTheme(*themeName); err != nil {
+		slog.Error("couldn't set the theme", "error", err)
+		return
+	}
+	for _, path := range imports {
+		result, err := c.state.Import(path)
+		if err != nil {
+			slog.Error("couldn't import constants", "file", path, "error", err)
+			return
+		}
+		for _, skipped := range result.Skipped {
+			slog.Warn("constant not imported", "file", path, "name", skipped.Name, "expr", skipped.Expr, "error", skipped.Err)
+		}
+		c.message = importSummary(path, result)
+	}
+	switch flag.Arg(0) {
+	case "map":
+		os.Exit(runMap(c.state, flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	case "table":
+		os.Exit(runTable(c.state, flag.Args()[1:], os.Stdout, os.Stderr))
+	}
 	err := c.AP.Open()
-	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{}))
-	slog.SetDefault(log)
 	if err != nil {
 		slog.Error("couldn't open terminal", "error", err)
 		return
 	}
 	defer func() {
 		c.AP.ShowCursor()
+		c.AP.SetBracketedPasteMode(false)
 		c.AP.MouseClickOff()
 		c.AP.Restore()
 		c.AP.ClearScreen()
@@ -68,45 +169,19 @@ func main() {
 	}()
 
 	c.AP.MouseClickOn()
-	c.AP.ClearScreen()
-	fmt.Println(c)
-	if c.AP.W < 38 || c.AP.H < 11 {
-		slog.Error("terminal window not large enough")
-		return
+	c.AP.SetBracketedPasteMode(true)
+	c.AP.OnResize = func() error {
+		c.AP.StartSyncMode()
+		c.draw()
+		c.AP.EndSyncMode()
+		return nil
 	}
-
 	err = c.AP.FPSTicks(func() bool {
-		c.AP.MoveCursor(c.index+1, c.AP.H-2)
-		diff := len(c.history) - (c.AP.H / 2) + 1
-		if diff > 0 {
-			c.history = c.history[diff:]
-		}
 		if !c.handleInput() {
 			return false
 		}
-		c.AP.ClearScreen()
-		if c.AP.H > 17 {
-			for i, str := range instructions {
-				c.AP.WriteAtStr(0, i, str)
-			}
-		}
-		strings := displayString(c.state.Ans, c.state.Err)
-		y := ap.H - 13
-		for i, str := range strings {
-			c.AP.WriteAtStr(0, y+i, str)
-		}
-		c.AP.WriteAtStr(0, c.AP.H, "⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯")
-		c.AP.WriteAtStr(0, c.AP.H-2, c.input)
-		c.DrawHistory()
-		c.AP.MoveCursor(c.index, c.AP.H-2)
-		if c.AP.LeftClick() && c.AP.MouseRelease() {
-			x, y := c.AP.Mx, c.AP.My
-			if slices.Contains(validClickXs, x) && y < c.AP.H-2 && y >= c.AP.H-6 {
-				bit := c.determineBitFromXY(x, c.AP.H-2-y)
-				c.clicked = true
-				c.state.Ans = (c.state.Ans) ^ (1 << bit)
-			}
-		}
+		c.handleMouse()
+		c.draw()
 		return true
 	})
 	if err != nil {
@@ -127,61 +202,58 @@ func (c *config) determineBitFromXY(x, y int) int {
 }
 
 func (c *config) handleInput() bool {
-	switch len(c.AP.Data) {
-	case 0:
+	if c.handlePaste(string(c.AP.Data)) {
 		return true
-	case 1:
-		switch c.AP.Data[0] {
-		case '\x03':
+	}
+	if c.help.open && len(c.AP.Data) > 0 && !c.AP.Mouse {
+		if c.AP.Data[0] == '\x03' {
 			return false
-		case '\x7f':
-			before, after := c.input[:max(0, c.index-1)], c.input[c.index:]
-			c.input = before + after
-			c.index = max(c.index-1, 0)
-		case '\r', '\n':
-			c.handleEnter()
-		default:
-			c.curRecord = -1
-			before, after := c.input[:c.index], c.input[c.index:]
-			c.input = before + string(c.AP.Data) + after
-			c.index++
 		}
+		c.handleHelpKey(string(c.AP.Data))
+		return true
+	}
+	if c.palette.open && len(c.AP.Data) > 0 && !c.AP.Mouse {
+		if c.AP.Data[0] == '\x03' {
+			return false
+		}
+		c.handlePaletteKey(string(c.AP.Data))
+		return true
+	}
+	if c.explanation.focused && c.view == explainView && len(c.AP.Data) > 0 && !c.AP.Mouse {
+		if c.AP.Data[0] == '\x03' {
+			return false
+		}
+		c.handleExplainKey(string(c.AP.Data))
+		return true
+	}
+	if c.table.focused && c.view == tableView && len(c.AP.Data) > 0 && !c.AP.Mouse {
+		if c.AP.Data[0] == '\x03' {
+			return false
+		}
+		c.handleTableKey(string(c.AP.Data))
+		return true
+	}
+	if c.vars.focused && len(c.AP.Data) > 0 && !c.AP.Mouse {
+		if c.AP.Data[0] == '\x03' {
+			return false
+		}
+		c.handleVariablesKey(string(c.AP.Data))
+		return true
+	}
+	data := string(c.AP.Data)
+	switch {
+	case data == "":
+	case data == "\x03":
+		return false
+	case data == "?" && c.input == "":
+		c.toggleHelp()
 	default:
-		switch string(c.AP.Data) {
-		case "\x1b[H": // home
-			c.index = 0
-		case "\x1b[F": // end
-			c.index = len(c.input)
-		case "\x1b[C": // right
-			c.index = min(c.index+1, len(c.input))
-		case "\x1b[D": // left
-			c.index = max(c.index-1, 0)
-		case "\x1b[A": // up
-			if len(c.history) > 1 {
-				switch c.curRecord {
-				case -1:
-					c.curRecord += len(c.history)
-				case 0:
-					c.curRecord += len(c.history) - 1
-				default:
-					c.curRecord--
-				}
-				c.input = c.history[c.curRecord].evaluated
-				c.index = len(c.input)
-			}
-		case "\x1b[B": // down
-			if len(c.history) > 1 {
-				c.curRecord = (c.curRecord + 1) % len(c.history)
-				c.input = c.history[c.curRecord].evaluated
-				if c.curRecord > 0 {
-					c.input = strings.Replace(c.history[c.curRecord].evaluated, "_ans_",
-						strconv.Itoa(int(c.history[c.curRecord-1].finalValue)), 1)
-				}
-				c.index = len(c.input)
-			}
-		case "\x1b[3~":
-			before, after := c.input[:c.index], c.input[min(len(c.input), c.index+1):]
-			c.input = before + after
+		if b, ok := keyBindingFor(data); ok {
+			b.run(c)
+		} else if base, ok := copyBases[strings.TrimPrefix(data, "\x1b")]; ok && len(data) == 2 {
+			c.copyResult(base)
+		} else if !strings.HasPrefix(data, "\x1b") {
+			c.insert(data) // multi-byte characters or fast typing
 		}
 	}
 	return true
@@ -189,11 +261,19 @@ func (c *config) handleInput() bool {
 
 func (c *config) handleEnter() {
 	defer func() { c.clicked = false }()
+	c.message = ""
+	if strings.HasPrefix(c.input, ":") {
+		c.runCommand(c.input)
+		c.refreshWatches() // commands change the mode, variables…
+		c.input, c.index = "", 0
+		return
+	}
 	if c.input == "" {
 		if c.clicked {
 			c.input = "(" + strconv.Itoa(int(c.state.Ans)) + ")"
 		} else {
-			c.input = c.history[len(c.history)-1].evaluated
+			last := c.history[len(c.history)-1]
+			c.input = cmp.Or(last.operation, last.evaluated)
 		}
 	}
 	trimmed := strings.Trim(c.input, " ")
@@ -201,24 +281,25 @@ func (c *config) handleEnter() {
 	if lengthTrimmed >= 2 && (trimmed[lengthTrimmed-2:] == "<<" || trimmed[lengthTrimmed-2:] == ">>") {
 		c.input += "1"
 	}
-	ansValue := "_ans_"
+	// refer to the previous result by number so that the entry keeps its meaning in history
+	ansValue := "ans"
+	if results := c.state.History; len(results) > 0 {
+		ansValue = "$" + strconv.Itoa(results[len(results)-1].ID)
+	}
 	if c.clicked {
 		ansValue = strconv.Itoa(int(c.state.Ans))
 	}
+	operation := ""
 	if (len(c.input) >= 2 && slices.Contains(calculator.Length2operators, calculator.DoubleRuneOperator(c.input[:2]))) ||
 		(len(c.input) > 0 && slices.Contains(calculator.Length1operatorsInfix, calculator.Operator(c.input[0]))) {
+		operation = c.input
 		c.input = ansValue + c.input
 	}
 	newRecord := historyRecord{
 		evaluated: c.input,
-	}
-	if len(c.history) > 1 {
-		ans := c.history[len(c.history)-2].finalValue
-		stringToReplace := strconv.Itoa(int(ans))
-		if stringToReplace[0] == '-' {
-			stringToReplace = "(" + stringToReplace + ")"
-		}
-		c.history[len(c.history)-1].evaluated = strings.ReplaceAll(c.history[len(c.history)-1].evaluated, "_ans_", stringToReplace)
+		operation: operation,
+		time:      time.Now(),
+		settings:  c.settings(),
 	}
 	err := c.state.Exec(c.input)
 	if err != nil {
@@ -227,36 +308,17 @@ func (c *config) handleEnter() {
 		c.state.Ans = c.history[len(c.history)-1].finalValue
 		return
 	}
+	c.refreshWatches()
+	newRecord.id = c.state.History[len(c.state.History)-1].ID
 	newRecord.finalValue = c.state.Ans
+	newRecord.real = formatReal(c.state)
+	if node, err := c.state.AST(newRecord.evaluated); err == nil {
+		newRecord.parsed = node.Parenthesized()
+	}
 	if newRecord.evaluated == "" {
 		newRecord.evaluated = strconv.Itoa(int(newRecord.finalValue))
 	}
 	c.history = append(c.history, newRecord)
+	c.historyScroll = 0
 	c.input, c.index = "", 0
 }
-
-func (c *config) DrawHistory() {
-	if c.AP.W > 76 {
-		c.AP.WriteAtStr(c.AP.W-27, c.AP.H, "⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯⎯")
-		for i := range c.AP.H {
-			c.AP.WriteAtStr(c.AP.W/2, i, "⏐")
-		}
-		for i, record := range c.history {
-			line := record.evaluated + ": " + strconv.Itoa(int(record.finalValue))
-			runes := make([]rune, len(line), c.AP.W/2-1)
-			for i := range line {
-				runes[i] = '⎯'
-			}
-			if c.curRecord == i {
-				for j := len(line); j < c.AP.W/2-1; j++ {
-					runes = append(runes, '⎯')
-				}
-				c.AP.WriteAtStr(c.AP.W-len(runes), c.AP.H-((len(c.history)-i)*2)+1, tcolor.Green.Foreground()+string(runes))
-			}
-			if c.curRecord != i-1 {
-				c.AP.WriteAtStr(c.AP.W-len(runes), c.AP.H-((len(c.history)-i)*2)-1, string(runes)+tcolor.Reset)
-			}
-			c.AP.WriteAtStr(c.AP.W-len(line), c.AP.H-((len(c.history)-i)*2), tcolor.Reset+line)
-		}
-	}
-}
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"fortio.org/terminal/ansipixels"
@@ -21,7 +30,7 @@ func TestDisplayStrings(t *testing.T) {
 	if ASCII(int64('a')) != "ASCII: a" {
 		t.Fail()
 	}
-	strs := displayString(64, errors.New("random error"))
+	strs := displayString(64, errors.New("random error"), calculator.QFormat{N: 15}, nil)
 	errCheck := tcolor.Red.Foreground() + "Last input was invalid" + tcolor.Reset
 	if strs[0] != errCheck {
 		t.Fail()
@@ -167,5 +176,1279 @@ func TestDrawHistory(t *testing.T) {
 			finalValue: 6,
 		})
 	c.curRecord = 2
+	c.relayout()
 	c.DrawHistory()
 }
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	header := filepath.Join(dir, "regs.h")
+	err := os.WriteFile(header, []byte(`#ifndef REGS_H
+#define REGS_H
+#define CTRL_EN    (1U << 0) /* enable */
+#define CTRL_MODE  (3UL << 4)
+#define CTRL_MASK  (CTRL_EN | CTRL_MODE)
+#define MIXED      1 | 2 << 3
+#define LATER      (EARLY + 1)
+#define EARLY      0x10
+#define NAME       "tcalc"
+#define VERSION    1.2.3
+#define MAX(a, b)  ((a) > (b) ? (a) : (b))
+enum color { RED, GREEN = 4, BLUE, };
+#endif
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "consts.go")
+	err = os.WriteFile(source, []byte(`package consts
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+const Flags = uint8(0xf0) &^ 0x30
+const Name = "tcalc"
+const Tab = '\t'
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := calculator.NewState()
+	result, err := s.Import(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int64{
+		"CTRL_EN": 1, "CTRL_MODE": 48, "CTRL_MASK": 49, "MIXED": 17,
+		"LATER": 17, "EARLY": 16, "RED": 0, "GREEN": 4, "BLUE": 5,
+	}
+	for name, value := range expected {
+		if s.Variables[name] != value {
+			t.Errorf("%s = %d, expected %d", name, s.Variables[name], value)
+		}
+	}
+	skipped := make([]string, 0, len(result.Skipped))
+	for _, constant := range result.Skipped {
+		skipped = append(skipped, constant.Name)
+	}
+	if slices.Sort(skipped); !slices.Equal(skipped, []string{"NAME", "VERSION"}) {
+		t.Errorf("expected NAME and VERSION to be skipped, got %v", result.Skipped)
+	}
+	result, err = s.Import(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Variables["KB"] != 1024 || s.Variables["MB"] != 1<<20 || s.Variables["Flags"] != 0xc0 || s.Variables["Tab"] != 9 {
+		t.Errorf("unexpected Go constants %v", s.Variables)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Name != "Name" {
+		t.Errorf("expected only Name to be skipped, got %v", result.Skipped)
+	}
+}
+
+func TestFloatInspector(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   int64
+	}{
+		{"f32(1.5)", 0x3fc00000},
+		{"f32(-2)", -0x40000000 & 0xffffffff},
+		{"f32(1e-3)", 0x3a83126f},
+		{"f64bits(1)", 0x3ff0000000000000},
+		{"f16(1.5)", 0x3e00},
+		{"f16(65504)", 0x7bff},
+		{"f16(1e6)", 0x7c00},
+		{"f16(0.5 + 2)", 0x4100},
+		{"7 & ~1", 6},
+		{"f32(1) | 1", 0x3f800001},
+	}
+	for _, tc := range testCases {
+		s := calculator.NewState()
+		if err := s.Exec(tc.expression); err != nil {
+			t.Errorf("Unexpected error for expression %s: %v", tc.expression, err)
+		} else if s.Ans != tc.expected {
+			t.Errorf("For expression %s, expected %#x but got %#x", tc.expression, tc.expected, s.Ans)
+		}
+	}
+	if calculator.Float16Value(0x3555) != 0.333251953125 {
+		t.Errorf("unexpected half value %v", calculator.Float16Value(0x3555))
+	}
+	strs := floatDisplayStrings(0x3fc00000, 32, nil)
+	if strs[2] != "Value: 1.5" || strs[3] != "Class: normal" {
+		t.Errorf("unexpected float view %q", strs[1:4])
+	}
+	if strs = floatDisplayStrings(0x7c01, 16, nil); strs[3] != "Class: signaling NaN" {
+		t.Errorf("unexpected half classification %q", strs[3])
+	}
+	if strs = floatDisplayStrings(1, 64, nil); strs[3] != "Class: subnormal" {
+		t.Errorf("unexpected double classification %q", strs[3])
+	}
+}
+
+func TestRealModes(t *testing.T) {
+	testCases := []struct {
+		mode       calculator.Mode
+		expression string
+		real       string // RatString of State.Real, "" for integer mode
+		ans        int64
+		shouldFail bool
+	}{
+		{calculator.IntegerMode, "7/2", "", 3, false},
+		{calculator.IntegerMode, "round(7/2)", "", 4, false},
+		{calculator.IntegerMode, "floor(-1.5)", "", -2, false},
+		{calculator.IntegerMode, "sqrt(17)", "", 4, false},
+		{calculator.IntegerMode, "1.5 + 1", "", 0, true},
+		{calculator.IntegerMode, "1e3", "", 1000, false},
+		{calculator.IntegerMode, "2**10", "", 1024, false},
+		{calculator.IntegerMode, "1/0", "", 0, true},
+		{calculator.FloatMode, "7/2", "7/2", 3, false},
+		{calculator.FloatMode, "1.5e3 * 2", "3000", 3000, false},
+		{calculator.FloatMode, "0.1 + 0.2", "1351079888211149/4503599627370496", 0, false},
+		{calculator.FloatMode, "ceil(2.1) | 8", "11", 11, false},
+		{calculator.FloatMode, "1.5 & 1", "", 0, true},
+		{calculator.FloatMode, "1/0", "", 0, true},
+		{calculator.RationalMode, "1/3 + (1/6)", "1/2", 0, false},
+		{calculator.RationalMode, "1/3 + 1/6", "2/9", 0, false}, // left to right like integers
+		{calculator.RationalMode, "(2/3) ** 2", "4/9", 0, false},
+		{calculator.RationalMode, "-7/2 % 2", "-3/2", -1, false},
+		{calculator.RationalMode, "round(-5/2)", "-3", -3, false},
+		{calculator.RationalMode, "int(10/3)", "3", 3, false},
+	}
+	for _, tc := range testCases {
+		s := calculator.NewState()
+		s.Mode = tc.mode
+		err := s.Exec(tc.expression)
+		if tc.shouldFail {
+			if err == nil {
+				t.Errorf("Expected failure for expression %s in %v mode", tc.expression, tc.mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for expression %s in %v mode: %v", tc.expression, tc.mode, err)
+			continue
+		}
+		got := ""
+		if s.Real != nil {
+			got = s.Real.RatString()
+		}
+		if got != tc.real || s.Ans != tc.ans {
+			t.Errorf("For expression %s in %v mode, expected %q (%d) but got %q (%d)",
+				tc.expression, tc.mode, tc.real, tc.ans, got, s.Ans)
+		}
+	}
+	s := calculator.NewState()
+	s.Mode = calculator.RationalMode
+	if err := s.Exec("third = 1/3"); err != nil || s.Variables["third"] != 0 {
+		t.Errorf("unexpected rational assignment %v %v", err, s.Variables)
+	}
+	if err := s.Exec("third * 3"); err != nil || s.Real.RatString() != "1" {
+		t.Errorf("rational variable lost precision: %v %v", err, s.Real)
+	}
+}
+
+func TestFixedPoint(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   int64
+	}{
+		{"q15(0.5)", 0x4000},
+		{"q15(-1)", -0x8000},
+		{"q15(1)", 0x7fff},
+		{"q31(0.25)", 0x20000000},
+		{"q(1.5, 3, 4)", 24},
+		{"uq(-1, 8, 8)", 0},
+		{"q15mul(0x4000, 0x4000)", 0x2000},
+		{"q15mul(-0x8000, -0x8000)", 0x7fff},
+		{"qmul(0x180, 0x180, 7, 8)", 0x240},
+		{"sat(300, 8)", 127},
+		{"usat(-5, 8)", 0},
+		{"round(fromq(0x6000, 15) * 4)", 3},
+	}
+	for _, tc := range testCases {
+		s := calculator.NewState()
+		if err := s.Exec(tc.expression); err != nil {
+			t.Errorf("Unexpected error for expression %s: %v", tc.expression, err)
+		} else if s.Ans != tc.expected {
+			t.Errorf("For expression %s, expected %d but got %d", tc.expression, tc.expected, s.Ans)
+		}
+	}
+	q, err := calculator.ParseQFormat("q1.14")
+	if err != nil || q != (calculator.QFormat{M: 1, N: 14}) {
+		t.Errorf("unexpected Q format %v %v", q, err)
+	}
+	if _, err = calculator.ParseQFormat("UQ40.40"); err == nil {
+		t.Error("expected UQ40.40 to be too wide")
+	}
+	if str := qDisplayString(0xc000, calculator.QFormat{N: 15}); str != "Q0.15: -0.5" {
+		t.Errorf("unexpected Q display %q", str)
+	}
+	if str := qDisplayString(0x180, calculator.QFormat{M: 8, N: 8, Unsigned: true}); str != "UQ8.8: 1.5" {
+		t.Errorf("unexpected UQ display %q", str)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   int64
+	}{
+		{"be16(0x1234)", 0x3412},
+		{"be32(0x11223344)", 0x44332211},
+		{"be64(0x0102030405060708)", 0x0807060504030201},
+		{"le32(0x1122334455)", 0x22334455},
+		{"byte(0x11223344, 1)", 0x33},
+		{"byte(-1, 7)", 0xff},
+	}
+	for _, tc := range testCases {
+		s := calculator.NewState()
+		if err := s.Exec(tc.expression); err != nil {
+			t.Errorf("Unexpected error for expression %s: %v", tc.expression, err)
+		} else if s.Ans != tc.expected {
+			t.Errorf("For expression %s, expected %#x but got %#x", tc.expression, tc.expected, s.Ans)
+		}
+	}
+	if err := calculator.NewState().Exec("byte(1, 8)"); err == nil {
+		t.Error("expected byte index 8 to fail")
+	}
+	strs := bytesDisplayStrings(0x1ff80, nil)
+	expected := []string{
+		"Bytes LE: 80 ff 01 00 00 00 00 00",
+		"Bytes BE: 00 00 00 00 00 01 ff 80",
+		"int8   -128   -1    1    0    0    0    0    0",
+		"uint8   128  255    1    0    0    0    0    0",
+		"int16    -128      1      0      0",
+	}
+	for i, line := range expected {
+		if strs[i+1] != line {
+			t.Errorf("line %d: expected %q, got %q", i+1, line, strs[i+1])
+		}
+	}
+}
+
+func TestCharacterPanel(t *testing.T) {
+	testCases := []struct {
+		num      int64
+		expected string
+	}{
+		{'a', "ASCII: a"},
+		{10, `ASCII: \n (LF)`},
+		{11, `ASCII: \v (VT)`},
+		{27, "ASCII: ^[ (ESC)"},
+		{0x7f, "ASCII: ^? (DEL)"},
+		{0xe9, "Unicode: é U+00E9"},
+		{-1, "ASCII: none"},
+		{0xd800, "ASCII: none"},
+	}
+	for _, tc := range testCases {
+		if got := ASCII(tc.num); got != tc.expected {
+			t.Errorf("ASCII(%d) = %q, expected %q", tc.num, got, tc.expected)
+		}
+	}
+	strs := charDisplayStrings(0x20ac, nil)
+	expected := []string{
+		"Char: €  U+20AC EURO SIGN",
+		"Category: Sc (currency symbol)",
+		`C escape: \u20ac  Caret: -`,
+		"UTF-8: e2 82 ac  UTF-16: 20ac",
+	}
+	for i, line := range expected {
+		if strs[i+1] != line {
+			t.Errorf("line %d: expected %q, got %q", i+1, line, strs[i+1])
+		}
+	}
+	strs = charDisplayStrings(0x46464952, nil)
+	if strs[5] != `Packed LE: "RIFF"  BE: "FFIR"` {
+		t.Errorf("unexpected packed string %q", strs[5])
+	}
+	if strs = charDisplayStrings(7, nil); strs[1] != `Char: \a  U+0007 BEL <control>` || strs[3] != `C escape: \a  Caret: ^G` {
+		t.Errorf("unexpected control character panel %q", strs[1:4])
+	}
+}
+
+func TestCharacterLiterals(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   int64
+		shouldFail bool
+	}{
+		{"'A'", 65, false},
+		{"'a' ^ 0x20", 'A', false},
+		{`'\n'`, 10, false},
+		{`'\x7f' & '\177'`, 0x7f, false},
+		{`'\0'`, 0, false},
+		{"'é'", 0xe9, false},
+		{`'€'`, 0x20ac, false},
+		{"'RIFF'", 0x52494646, false},
+		{`"RIFF"`, 0x46464952, false},
+		{`"a b"`, 0x622061, false},
+		{`x = "=" + 1`, '=' + 1, false},
+		{`"\x89PNG\r\n\x1a\n"`, 0x0a1a0a0d474e5089, false},
+		{`"123456789"`, 0, true},
+		{`'\q'`, 0, true},
+		{`'A`, 0, true},
+	}
+	for _, tc := range testCases {
+		s := calculator.NewState()
+		err := s.Exec(tc.expression)
+		if tc.shouldFail {
+			if err == nil {
+				t.Errorf("Expected failure for expression: %s", tc.expression)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for expression %s: %v", tc.expression, err)
+		} else if s.Ans != tc.expected {
+			t.Errorf("For expression %s, expected %#x but got %#x", tc.expression, tc.expected, s.Ans)
+		}
+	}
+	s := calculator.NewState()
+	s.BigEndianStrings = true
+	if err := s.Exec(`"RIFF"`); err != nil || s.Ans != 0x52494646 {
+		t.Errorf("unexpected big endian string %#x %v", s.Ans, err)
+	}
+	for _, literal := range []string{"''", `'\777'`} {
+		if err := s.Exec(literal); err == nil || strings.Contains(err.Error(), "invalid number") {
+			t.Errorf("%s should report the literal's own error, got %v", literal, err)
+		}
+	}
+}
+
+func TestClipboardAndPaste(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.Out = bufio.NewWriter(io.Discard)
+	for _, chunk := range []string{"\x1b[200~1+1\n\n", "x = 4\r\n \n\nx <", "< 2\x1b[201~"} {
+		c.AP.Data = []byte(chunk)
+		if !c.handleInput() {
+			t.Fatal("handleInput stopped during paste")
+		}
+	}
+	if len(c.history) != 3 || c.history[1].finalValue != 2 || c.state.Variables["x"] != 4 {
+		t.Errorf("pasted lines were not evaluated: %v", c.history)
+	}
+	if c.input != "x << 2" || c.index != len(c.input) {
+		t.Errorf("expected the last pasted line in the input, got %q at %d", c.input, c.index)
+	}
+	c.handleEnter()
+	c.copyResult(16)
+	if c.message != "copied 0x10 to the clipboard" {
+		t.Errorf("unexpected copy message %q", c.message)
+	}
+	c.AP.Data = []byte("\x1bb")
+	c.handleInput()
+	if c.message != "copied 0b10000 to the clipboard" {
+		t.Errorf("unexpected copy message %q", c.message)
+	}
+	c.copyEntry()
+	if c.message != "copied x << 2 = 16 to the clipboard" {
+		t.Errorf("unexpected copy message %q", c.message)
+	}
+}
+
+func TestHistoryPane(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.H, c.AP.W = 12, 100
+	c.relayout()
+	for _, input := range []string{"1+1", "$1*3", "_2+1", "10", "20", "30", "40"} {
+		c.input = input
+		c.handleEnter()
+		if c.state.Err != nil {
+			t.Fatalf("%s: %v", input, c.state.Err)
+		}
+	}
+	if got := c.history[3]; got.id != 3 || got.finalValue != 7 {
+		t.Errorf("history[3] = %+v, want $3 = 7", got)
+	}
+	if len(c.history) != 8 {
+		t.Fatalf("history has %d entries, want all 8 kept", len(c.history))
+	}
+	first, last := c.visibleHistory()
+	if first != 3 || last != 7 {
+		t.Errorf("visible history = %d..%d, want 3..7", first, last)
+	}
+	c.AP.Data = []byte("\x1b[5~") // page up
+	c.handleInput()
+	if first, last = c.visibleHistory(); first != 0 || last != 4 {
+		t.Errorf("after page up visible history = %d..%d, want 0..4", first, last)
+	}
+	c.handleClick(c.AP.W-1, c.historyRow(2, last)+1)
+	if c.input != "$1*3" || c.curRecord != 2 {
+		t.Errorf("clicking entry 2 loaded %q (record %d)", c.input, c.curRecord)
+	}
+	c.recallRecord(3)
+	if c.state.Ans != 7 {
+		t.Errorf("recalling $3 gave Ans = %d, want 7", c.state.Ans)
+	}
+	c.runCommand(":delete $2")
+	if _, ok := c.state.Result(2); ok || len(c.history) != 7 || c.history[2].id != 3 {
+		t.Errorf(":delete $2 left history %+v", c.history)
+	}
+	if err := c.state.Exec("$2"); err == nil {
+		t.Error("$2 still resolves after deletion")
+	}
+	if err := c.state.Exec("$3 + _4"); err != nil || c.state.Ans != 17 {
+		t.Errorf("$3 + _4 = %d, %v, want 17", c.state.Ans, err)
+	}
+	c.state.Exec("_5 = 1")
+	if err := c.state.Exec("_5 + 1"); err != nil || c.state.Ans != 2 {
+		t.Errorf("assigned _5 should shadow the result, got %d, %v", c.state.Ans, err)
+	}
+	c.DrawHistory()
+	// entries wider than the pane keep their end, the result
+	var out bytes.Buffer
+	c.AP.Out = bufio.NewWriter(&out)
+	c.AP.W, c.AP.H = 80, 30
+	c.relayout()
+	c.input = strings.Repeat("1 + ", 20) + "'é'"
+	c.handleEnter()
+	c.curRecord = len(c.history) - 1
+	c.DrawHistory()
+	c.AP.Out.Flush()
+	if !strings.Contains(out.String(), "…1) + 1) + 1) + 1) + 1) + 1) + 'é': 253") {
+		t.Errorf("long entry drawn as %q", out.String())
+	}
+}
+
+func TestResultReferences(t *testing.T) {
+	s := calculator.NewState()
+	for _, input := range []string{"10", "20", "30"} {
+		if err := s.Exec(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"ans", 30},
+		{"_ans_ + 1", 31},
+		{"$$", 31},
+		{"ans1 + ans2", 62},
+		{"$-3 - $1", 21},
+		{"$2 * _3", 600},
+		{"ans3", 62},
+	}
+	for _, tt := range tests {
+		if err := s.Exec(tt.input); err != nil || s.Ans != tt.want {
+			t.Errorf("%s = %d, %v, want %d", tt.input, s.Ans, err, tt.want)
+		}
+	}
+	for _, input := range []string{"$-100", "ans0", "$42"} {
+		if err := s.Exec(input); err == nil {
+			t.Errorf("%s should fail", input)
+		}
+	}
+	s.Mode = calculator.RationalMode
+	if err := s.Exec("$1 / 4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Exec("$$ * 2"); err != nil || s.Real.RatString() != "5" {
+		t.Errorf("$$ * 2 = %v, %v, want the exact 5/2 * 2", s.Real, err)
+	}
+
+	c := configure(ansipixels.NewAnsiPixels(30))
+	for _, input := range []string{"5", "+1", "", "-1"} {
+		c.input = input
+		c.handleEnter()
+	}
+	got := make([]string, 0, len(c.history))
+	for _, record := range c.history[1:] {
+		got = append(got, record.evaluated+"="+record.result())
+	}
+	want := []string{"5=5", "$1+1=6", "$2+1=7", "$3-1=6"}
+	if !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	wide := computeLayout(120, 40, panelSizes{results: 12})
+	if !wide.sideHistory || wide.history != (rect{61, 0, 59, 40}) || wide.instructions.empty() {
+		t.Errorf("wide layout = %+v", wide)
+	}
+	if wide.results != (rect{0, 25, 60, 12}) || wide.input != (rect{0, 38, 60, 2}) {
+		t.Errorf("wide results %+v, input %+v", wide.results, wide.input)
+	}
+	tall := computeLayout(50, 50, panelSizes{results: 12})
+	if tall.sideHistory || tall.history != (rect{0, len(instructions), 50, 35 - len(instructions)}) {
+		t.Errorf("tall narrow layout should stack history above the results: %+v", tall)
+	}
+	short := computeLayout(50, 10, panelSizes{results: 12})
+	if short.tooSmall || short.results.h != 7 || !short.instructions.empty() || !short.history.empty() {
+		t.Errorf("short layout should only cut the results: %+v", short)
+	}
+	for _, size := range [][2]int{{39, 30}, {80, 7}} {
+		if l := computeLayout(size[0], size[1], panelSizes{results: 12}); !l.tooSmall {
+			t.Errorf("%dx%d should be too small", size[0], size[1])
+		}
+	}
+
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 20, 5
+	c.draw() // must not panic nor exit
+	c.AP.W, c.AP.H = 50, 60
+	c.input = "1+1"
+	c.handleEnter()
+	c.draw()
+	if c.layout.history.empty() || c.historyRow(1, 1) != c.layout.history.y+c.layout.history.h-2 {
+		t.Errorf("stacked history pane misplaced: %+v", c.layout.history)
+	}
+
+	if got := clip(tcolor.Red.Foreground()+"abc"+tcolor.Reset+"déf", 4); got != tcolor.Red.Foreground()+"abc"+tcolor.Reset+"d"+tcolor.Reset {
+		t.Errorf("clip counted the colors: %q", got)
+	}
+	if got := clip("abc", 3); got != "abc" {
+		t.Errorf("clip cut a line that fits: %q", got)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	defer c.setTheme("dark")
+	for _, name := range themeNames() {
+		if err := c.setTheme(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.runCommand(":theme solarized")
+	if c.themeName != "monochrome" || c.message == "" {
+		t.Errorf("unknown theme should be refused, got %q", c.themeName)
+	}
+
+	c.colorDepth = noColors
+	c.runCommand(":theme dark")
+	if colors[errorElement] != "" || colors[newlySetElement] != tcolor.Bold {
+		t.Errorf("NO_COLOR should keep only attributes: %q", colors)
+	}
+	c.colorDepth = trueColors
+	c.applyTheme()
+	if colors[errorElement] != "\x1b[38;2;255;95;95m" {
+		t.Errorf("truecolor error = %q", colors[errorElement])
+	}
+	c.colorDepth = colors256
+	c.runCommand(":color error 00ff00")
+	if colors[errorElement] != "\x1b[38;5;46m" {
+		t.Errorf(":color error 00ff00 in 256 colors = %q", colors[errorElement])
+	}
+	c.colorDepth = basicColors
+	c.runCommand(":color operator none")
+	if colors[operatorElement] != "" || colors[errorElement] != "" {
+		t.Errorf("basic colors: operator %q, rich only error %q", colors[operatorElement], colors[errorElement])
+	}
+	c.runCommand(":color operator yellow")
+	if got := highlightInput(`'+' + 1`); got != `'+' `+tcolor.Yellow.Foreground()+"+"+tcolor.Reset+" 1" {
+		t.Errorf("highlightInput = %q", got)
+	}
+	c.runCommand(":color bits red")
+	if c.message == "" {
+		t.Error("unknown element should be refused")
+	}
+
+	c.runCommand(":theme monochrome")
+	grid := binaryDisplayStrings(1)
+	if want := tcolor.Bold + "1" + tcolor.Reset; grid[4][len(grid[4])-len(want):] != want {
+		t.Errorf("set bit in monochrome = %q", grid[4])
+	}
+}
+
+func TestChangedBits(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	if _, ok := c.previousValue(); ok {
+		t.Error("nothing to compare with before the first result")
+	}
+	for _, input := range []string{"0xf0", "ans & ~0x30 | 1"} {
+		c.input = input
+		c.handleEnter()
+	}
+	previous, ok := c.previousValue()
+	if !ok || previous != 0xf0 {
+		t.Fatalf("previous value = %#x, %v, want 0xf0", previous, ok)
+	}
+	display := c.resultStrings()
+	summary := "Changed: 3 (+" + paint(newlySetElement, "1") + " -" + paint(newlyClearedElement, "2") + ") bits 5..0 ^0x31"
+	if !slices.Contains(display, summary) {
+		t.Errorf("missing %q in %q", summary, display)
+	}
+	grid := display[len(display)-1]
+	want := "  1 1 " + paint(newlyClearedElement, "0") + " " + paint(newlyClearedElement, "0") +
+		"  0 0 0 " + paint(newlySetElement, "1")
+	if grid[len(grid)-len(want):] != want {
+		t.Errorf("low bits = %q, want suffix %q", grid, want)
+	}
+
+	c.state.Ans ^= 1 << 8 // clicking a bit compares with the last result
+	if previous, _ = c.previousValue(); previous != 0xc1 {
+		t.Errorf("after a click previous = %#x, want 0xc1", previous)
+	}
+	if got := diffDisplayString(0xc1, 0xc1); got != "Changed: none" {
+		t.Errorf("diff of equal values = %q", got)
+	}
+}
+
+func TestCompareView(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	for _, input := range []string{"mask = 0x0f", "0xf0", "0x3c"} {
+		c.input = input
+		c.handleEnter()
+	}
+	tests := []struct {
+		args string
+		want comparison
+	}{
+		{"", comparison{"$-2", "$$"}},
+		{"$2 $3", comparison{"$2", "$3"}},
+		{"q(1, 2, 3), mask | 1", comparison{"q(1, 2, 3)", "mask | 1"}},
+	}
+	for _, tt := range tests {
+		got, err := parseComparison(tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("parseComparison(%q) = %v, %v, want %v", tt.args, got, err, tt.want)
+		}
+	}
+	if _, err := parseComparison("1 2 3"); err == nil {
+		t.Error("three operands should be refused")
+	}
+
+	c.runCommand(":compare")
+	display := c.resultStrings()
+	if c.view != compareView || !slices.Contains(display, "a ^ b  0xcc, 4 bits differ") ||
+		!slices.Contains(display, "a & ~b 0xc0") || !slices.Contains(display, "~a & b 0xc") {
+		t.Errorf("compare view = %q", display)
+	}
+	if c.state.Ans != 0x3c || len(c.state.History) != 3 {
+		t.Errorf("comparing changed the state: Ans %#x, %d results", c.state.Ans, len(c.state.History))
+	}
+	c.runCommand(":compare mask, $2")
+	c.state.Exec("mask = 0xf0") // operands follow the variables
+	if display = c.resultStrings(); !slices.Contains(display, "a ^ b  0x0, 0 bits differ") {
+		t.Errorf("compare view after changing mask = %q", display)
+	}
+	c.runCommand(":compare $9, 1")
+	if display = c.resultStrings(); !strings.Contains(display[1], "no result $9") {
+		t.Errorf("compare with a missing result = %q", display)
+	}
+	for _, args := range []string{"~, -", "1, ~"} {
+		if err := c.execCommand(":compare " + args); err == nil || c.compare.b == "-" || c.compare.b == "~" {
+			t.Errorf(":compare %s should be refused, comparing %+v", args, c.compare)
+		}
+	}
+	c.state.Variables["n"] = 5
+	c.runCommand(":compare n = n + 1, 0")
+	for range 3 {
+		display = c.resultStrings()
+	}
+	if c.state.Variables["n"] != 5 || !strings.Contains(display[1], "can't assign n") {
+		t.Errorf("redrawing the comparison assigned n = %d: %q", c.state.Variables["n"], display)
+	}
+	c.runCommand(":compare off")
+	if c.view != integerView {
+		t.Error(":compare off should go back to the integer view")
+	}
+}
+
+func TestWatches(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 120, 40
+	for _, input := range []string{":watch base + off", "base = 0x1000", "off = 0x24", ":watch popcount(base | off)", ":watch nope(1)"} {
+		c.input = input
+		c.handleEnter()
+	}
+	if len(c.watches) != 3 {
+		t.Fatalf("watches = %v", c.watches)
+	}
+	if got := c.watches[0].String(); got != "base + off = 4132  0x1024  010044  0b1000000100100" {
+		t.Errorf("watch 1 = %q", got)
+	}
+	if c.watches[1].value != 3 || c.watches[2].err == nil {
+		t.Errorf("watches 2 and 3 = %+v", c.watches[1:])
+	}
+	c.input = "off = 0"
+	c.handleEnter()
+	if c.watches[0].value != 0x1000 || c.watches[1].value != 1 {
+		t.Errorf("watches not refreshed after Exec: %+v", c.watches)
+	}
+	if c.state.Ans != 0 {
+		t.Errorf("watches changed Ans to %d", c.state.Ans)
+	}
+	c.draw()
+	if c.layout.watch != (rect{61, 0, 59, 4}) || c.layout.history.y != 4 {
+		t.Errorf("watch pane %+v, history %+v", c.layout.watch, c.layout.history)
+	}
+	if err := c.execCommand(":watch off = off + 1"); err == nil || len(c.watches) != 3 || c.state.Variables["off"] != 0 {
+		t.Errorf("watching an assignment: %v, off = %d", err, c.state.Variables["off"])
+	}
+	for _, expr := range []string{"~", "-", "x = )", "nope("} {
+		if err := c.execCommand(":watch " + expr); err == nil || len(c.watches) != 3 {
+			t.Errorf(":watch %s should be refused", expr)
+		}
+	}
+	c.runCommand(":unwatch 3")
+	c.runCommand(":unwatch 7")
+	if len(c.watches) != 2 || c.message == "" {
+		t.Errorf("after :unwatch 3 and 7: %v, %q", c.watches, c.message)
+	}
+	c.runCommand(":unwatch all")
+	if c.watchLines() != 0 {
+		t.Error(":unwatch all left watches")
+	}
+}
+
+func TestVariablesPanel(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 120, 40
+	for _, input := range []string{"zeta = 1", "alpha = 0x30", "mid = 2"} {
+		c.input = input
+		c.handleEnter()
+	}
+	press := func(keys ...string) {
+		for _, key := range keys {
+			c.AP.Data = []byte(key)
+			c.handleInput()
+		}
+	}
+	press("\x1bOQ") // F2
+	if !c.vars.shown || !c.vars.focused || c.variablesLines() != 4 {
+		t.Fatalf("F2 should show and focus the panel: %+v", c.vars)
+	}
+	if got := c.sortedVariables(); !slices.Equal(got, []string{"alpha", "mid", "zeta"}) {
+		t.Errorf("by name = %v", got)
+	}
+	press("s")
+	if got := c.sortedVariables(); !slices.Equal(got, []string{"zeta", "mid", "alpha"}) {
+		t.Errorf("by value = %v", got)
+	}
+	press("\x1b[B", "\x1b[B", "\x1b[B", "b") // down past the end, then decimal
+	if name, _ := c.selectedVariable(); name != "alpha" || c.formatVariable(name) != "48" {
+		t.Errorf("selected %s = %s", name, c.formatVariable(name))
+	}
+	press("e")
+	if c.input != "alpha = 48" || c.vars.focused {
+		t.Errorf("e should edit alpha and give the focus back, input %q", c.input)
+	}
+	c.input, c.index = "1 + ", 4
+	press("\x1b[12~", "\x1b[A", "\r")
+	if c.input != "1 + mid" {
+		t.Errorf("enter should insert the selected name, input %q", c.input)
+	}
+	press("\x1bOQ", "d")
+	if _, ok := c.state.Variables["mid"]; ok {
+		t.Error("d should delete the selected variable")
+	}
+	c.draw()
+	if c.layout.variables.empty() {
+		t.Fatal("variables pane not laid out")
+	}
+	name, ok := c.variableAt(c.layout.variables.x+2, c.layout.variables.y+2)
+	if !ok || name != "zeta" {
+		t.Errorf("first variable row is %q", name)
+	}
+	press("\x1bOQ") // hides it when focused
+	if c.vars.shown || c.variablesLines() != 0 {
+		t.Error("F2 with the focus should hide the panel")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 120, 40
+	if err := c.execCommand(":width 300"); err == nil || err.Error() != "usage: :width 1-64" {
+		t.Errorf(":width 300 = %v", err)
+	}
+	if err := c.execCommand(":nope"); err == nil {
+		t.Error("unknown commands should fail")
+	}
+	press := func(keys ...string) {
+		for _, key := range keys {
+			c.AP.Data = []byte(key)
+			c.handleInput()
+		}
+	}
+	c.input, c.index = ":wi", 3
+	press("\t")
+	if c.input != ":width " {
+		t.Errorf("completed %q", c.input)
+	}
+	c.input, c.index = ":signedness u", 13
+	press("\t")
+	if c.input != ":signedness unsigned" {
+		t.Errorf("completed %q", c.input)
+	}
+	c.input, c.index = ":s", 2
+	press("\t")
+	if c.input != ":s" || !strings.HasPrefix(c.message, "save set signedness ") {
+		t.Errorf("ambiguous completion %q: %q", c.input, c.message)
+	}
+	press("\x10", "w", "d", "t", "\r") // ctrl+p, a fuzzy match on width
+	if c.palette.open || c.input != ":width " {
+		t.Errorf("the palette should start :width, input %q", c.input)
+	}
+	for _, input := range []string{":width 8", ":signedness unsigned", "250 + 10", ":set big 0x1ff", "x = 3"} {
+		c.input = input
+		c.handleEnter()
+	}
+	if c.history[1].finalValue != 4 || c.state.Variables["big"] != 0xff {
+		t.Errorf("250 + 10 = %d wrapped to 8 bits, big = %d", c.history[1].finalValue, c.state.Variables["big"])
+	}
+	if len(c.history) != 3 {
+		t.Errorf(":set shouldn't add to history: %+v", c.history)
+	}
+	// assigned values are wrapped, the operators themselves work on 64 bits
+	for _, tc := range []struct {
+		input string
+		want  int64
+	}{{"y = 0xff + 1", 0}, {"y >> 4", 0}, {"z = -1", 0xff}, {"z >> 4", 0xf}, {"-1 >> 4", 0xff}} {
+		if err := c.state.Exec(tc.input); err != nil || c.state.Ans != tc.want {
+			t.Errorf("%s = %#x, %v, want %#x at 8 unsigned bits", tc.input, c.state.Ans, err, tc.want)
+		}
+	}
+	if c.state.Variables["y"] != 0 || c.state.Variables["z"] != 0xff {
+		t.Errorf("assignments stored %v", c.state.Variables)
+	}
+	for _, input := range []string{"~", "-", "1 + ~"} {
+		if err := c.execCommand(":set w " + input); err == nil {
+			t.Errorf(":set w %s should fail", input)
+		}
+		if _, err := c.state.Evaluate(input); err == nil {
+			t.Errorf("Evaluate(%q) should fail", input)
+		}
+	}
+	dir := t.TempDir()
+	session, export := dir+"/session.tcalc", dir+"/history.txt"
+	for _, command := range []string{":watch x * 2", ":save " + session, ":export " + export, ":clear all"} {
+		if err := c.execCommand(command); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+	}
+	if len(c.history) != 1 || len(c.state.Variables) != 0 {
+		t.Errorf(":clear all left %+v %v", c.history, c.state.Variables)
+	}
+	exported, err := os.ReadFile(export)
+	if err != nil || string(exported) != "250 + 10 = 4\nx = 3 = 3\n" {
+		t.Errorf("exported %q, %v", exported, err)
+	}
+	c = configure(ansipixels.NewAnsiPixels(30))
+	if err := c.execCommand(":load " + session); err != nil {
+		t.Fatal(err)
+	}
+	if c.state.Width != 8 || !c.state.Unsigned || len(c.history) != 3 || c.state.Variables["big"] != 0xff ||
+		len(c.watches) != 1 || c.watches[0].value != 6 {
+		t.Errorf("loaded width %d, history %+v, variables %v, watches %+v",
+			c.state.Width, c.history, c.state.Variables, c.watches)
+	}
+	c = configure(ansipixels.NewAnsiPixels(30))
+	for _, input := range []string{"1", "2", ":clear history", "5", "+1", ":mode rational", "r = 1/3", ":mode int"} {
+		if strings.HasPrefix(input, ":") {
+			c.runCommand(input)
+			continue
+		}
+		c.input = input
+		c.handleEnter()
+	}
+	if err := c.execCommand(":save " + session); err != nil {
+		t.Fatal(err)
+	}
+	c = configure(ansipixels.NewAnsiPixels(30))
+	if err := c.execCommand(":load " + session); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.history) != 4 || c.history[2].finalValue != 6 || c.history[3].result() != "1/3" || c.state.Mode != calculator.IntegerMode {
+		t.Errorf("loaded history %+v in mode %v", c.history, c.state.Mode)
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	for _, input := range []string{":set k 5", ":base hex", "y = k + 1", "2 * 3", "y + $1", "z = $3 * 2", "(-5) + $3"} {
+		if strings.HasPrefix(input, ":") {
+			if err := c.execCommand(input); err != nil {
+				t.Fatalf("%s: %v", input, err)
+			}
+			continue
+		}
+		c.input = input
+		c.handleEnter()
+	}
+	c.deleteRecord(2)
+	dir := t.TempDir()
+	for _, name := range []string{"history.md", "history.csv", "history.json", "replay.tcalc"} {
+		if err := c.execCommand(":export " + dir + "/" + name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if err := c.execCommand(":export " + dir + "/history yaml"); err == nil {
+		t.Error("unknown formats should fail")
+	}
+	read := func(name string) string {
+		text, err := os.ReadFile(dir + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(text)
+	}
+	md := strings.Split(read("history.md"), "\n")
+	if md[0] != "| # | expression | result | hex | time |" || !strings.HasPrefix(md[4], "| $4 | z = $3 * 2 | 24 | 0x18 | ") {
+		t.Errorf("markdown %q", md)
+	}
+	if csv := strings.Split(read("history.csv"), "\n"); !strings.HasPrefix(csv[2], "$3,y + $1,12,0xc,") {
+		t.Errorf("csv %q", csv)
+	}
+	var entries []exportedEntry
+	if err := json.Unmarshal([]byte(read("history.json")), &entries); err != nil || len(entries) != 4 ||
+		entries[2].ID != 4 || entries[2].Bases["hex"] != "0x18" || entries[2].Mode != "int" {
+		t.Errorf("json %+v, %v", entries, err)
+	}
+	script := read("replay.tcalc")
+	if !strings.Contains(script, ":set k 5\n") || !strings.Contains(script, "\nz = $2 * 2\n") ||
+		!strings.Contains(script, "# $4 = 7 at ") || !strings.Contains(script, "\n(-5) + $2\n") {
+		t.Errorf("script %q", script)
+	}
+	replayed := configure(ansipixels.NewAnsiPixels(30))
+	if err := replayed.execCommand(":load " + dir + "/replay.tcalc"); err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(replayed.state.Variables, c.state.Variables) || len(replayed.history) != 5 ||
+		replayed.history[4].finalValue != 7 {
+		t.Errorf("replayed %v, want %v", replayed.state.Variables, c.state.Variables)
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	for _, op := range calculator.Operators() {
+		if op.Help == "" {
+			t.Errorf("operator %s has no help", op.Form)
+		}
+	}
+	text := strings.Join(helpLines(), "\n")
+	var wanted []string
+	for _, f := range calculator.Functions {
+		wanted = append(wanted, f.Signature()+" ")
+	}
+	for _, cmd := range commands {
+		wanted = append(wanted, cmd.usage()+" ")
+	}
+	for _, b := range keyBindings {
+		wanted = append(wanted, b.name)
+	}
+	for _, want := range wanted {
+		if !strings.Contains(text, want) {
+			t.Errorf("help lacks %q", want)
+		}
+	}
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 80, 20
+	press := func(keys ...string) {
+		for _, key := range keys {
+			c.AP.Data = []byte(key)
+			c.handleInput()
+		}
+	}
+	c.input, c.index = "'", 1
+	press("?")
+	if c.help.open || c.input != "'?" {
+		t.Fatalf("? in an expression should be typed, input %q", c.input)
+	}
+	c.input, c.index = "", 0
+	press("?", "\x1b[6~", "\x1b[B")
+	if !c.help.open || c.help.scroll != c.AP.H {
+		t.Errorf("help open %v scrolled to %d", c.help.open, c.help.scroll)
+	}
+	press("\x1b[F", "\x1b[B")
+	if last := len(helpLines()) - c.helpRows(); c.help.scroll != last {
+		t.Errorf("scrolled to %d past the end %d", c.help.scroll, last)
+	}
+	press("1", "\x1b")
+	if c.help.open || c.input != "" {
+		t.Errorf("keys should go to the overlay until Esc, input %q", c.input)
+	}
+	press("\x1bOP")
+	if !c.help.open {
+		t.Error("F1 should open the help")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.state.Variables["x"] = 12
+	steps, err := c.state.Explain("y = ((x - 1) & x) ^ x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exprs []string
+	var values []int64
+	for _, step := range steps {
+		exprs = append(exprs, step.Expr)
+		values = append(values, step.Value)
+	}
+	wantExprs := []string{"x", "1", "x - 1", "x", "(x - 1) & x", "x", "((x - 1) & x) ^ x", "y = ((x - 1) & x) ^ x"}
+	if !slices.Equal(exprs, wantExprs) || !slices.Equal(values, []int64{12, 1, 11, 12, 8, 12, 4, 4}) {
+		t.Errorf("steps %q = %v", exprs, values)
+	}
+	if steps[2].Parent != 4 || steps[2].Depth != 3 || steps[7].Parent != -1 {
+		t.Errorf("x - 1 has parent %d at depth %d", steps[2].Parent, steps[2].Depth)
+	}
+	if _, ok := c.state.Variables["y"]; ok {
+		t.Error("explaining an assignment shouldn't assign")
+	}
+	steps, _ = c.state.Explain("f32(1.5)")
+	if steps[0].Err != nil || steps[1].Value != 0x3fc00000 {
+		t.Errorf("f32 reads its argument as a real: %+v", steps)
+	}
+	for _, input := range []string{"~", "~-1"} {
+		if _, err := c.state.Explain(input); err == nil {
+			t.Errorf("explaining %s should fail", input)
+		}
+	}
+	c.input = "0b1100 & 0b1010"
+	c.handleEnter()
+	c.input = ":explain"
+	c.handleEnter()
+	press := func(keys ...string) {
+		for _, key := range keys {
+			c.AP.Data = []byte(key)
+			c.handleInput()
+		}
+	}
+	press("\x1b[C", "\x1b[C", "\x1b[C", "\x1b[D")
+	if display := c.explainDisplayStrings(); c.view != explainView || c.input != "" ||
+		!strings.Contains(display[1], "step 2/3") || display[2] != "0b1100 & 0b1010 = …" {
+		t.Errorf("tree %q", display)
+	}
+	press("\x1b[F")
+	if display := c.explainDisplayStrings(); !strings.Contains(display[2], "0b1100 & 0b1010 = 8  0x8  0b1000") {
+		t.Errorf("tree %q", display)
+	}
+	press("t", "\x1b", "1")
+	if !c.explanation.table || c.explanation.focused || c.input != "1" {
+		t.Errorf("Esc should give the keys back to the input, input %q", c.input)
+	}
+}
+
+func TestAST(t *testing.T) {
+	s := calculator.NewState()
+	node, err := s.AST("y = ~x + max(1, $3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := node.Children[0]
+	not, call := sum.Children[0], sum.Children[1]
+	if node.Kind != calculator.Assign || node.Name != "y" || node.Pos != 2 ||
+		sum.Kind != calculator.Binary || sum.Op != "+" || sum.Pos != 7 ||
+		not.Kind != calculator.Unary || not.Children[0].Kind != calculator.Identifier ||
+		call.Kind != calculator.Call || call.Name != "max" || call.Pos != 9 ||
+		call.Children[0].Kind != calculator.Literal || call.Children[1].Value != "$3" || call.Children[1].Pos != 16 {
+		t.Errorf("tree %+v", node)
+	}
+	for _, tt := range []struct{ input, canonical, parenthesized string }{
+		{"(2*(3+2)-1)+1/1*3", "2 * (3 + 2) - 1 + 1 / 1 * 3", "((((2 * (3 + 2)) - 1) + 1) / 1) * 3"},
+		{"1-(-2)", "1 - (-2)", "1 - (-2)"},
+		{"-(1+2)<<1", "-(1 + 2) << 1", "-(1 + 2) << 1"},
+		{"f32(1.5/2)", "f32(1.5 / 2)", "f32(1.5 / 2)"},
+	} {
+		node, err := s.AST(tt.input)
+		if err != nil || node.String() != tt.canonical || node.Parenthesized() != tt.parenthesized {
+			t.Errorf("%s = %q, %q, %v", tt.input, node.String(), node.Parenthesized(), err)
+		}
+		again, _ := s.AST(node.String())
+		if again.Parenthesized() != tt.parenthesized {
+			t.Errorf("%s doesn't parse back the same: %q", node.String(), again.Parenthesized())
+		}
+	}
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.input = "1+2*3"
+	c.handleEnter()
+	if got := historyLabel(c.history[1]); got != "$1 (1 + 2) * 3: 9" {
+		t.Errorf("history shows %q", got)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	s := calculator.NewState()
+	for _, tt := range []struct{ input, want string }{
+		{"(x << 3) >> 3 & 0xff | 0", "x & 0xff"},
+		{"(x << 8) >> 8 & 0xff00000000000000", "x << 8 >> 8 & 0xff00000000000000"},
+		{"x & (-1) ^ 0 * 1", "x"},
+		{"~~x + (2 * 3)", "x + 6"},
+		{"x + 3 - 5", "x - 2"},
+		{"x - (-y)", "x + y"},
+		{"x & 0xf0 & 0x3c", "x & 0x30"},
+		{"x << 60 << 10", "0"},
+		{"x >> 40 >> 40", "x >> 63"},
+		{"x * 0 | 'A'", "65"},
+		{"y = 2 + 2 - x", "y = 4 - x"},
+		{"max(1, 2) + x", "max(1, 2) + x"},
+		{"x / 0 + 0", "x / 0"},
+	} {
+		node, err := s.AST(tt.input)
+		if err != nil {
+			t.Fatal(tt.input, err)
+		}
+		if got := s.Simplify(node).String(); got != tt.want {
+			t.Errorf("%s simplifies to %s, want %s", tt.input, got, tt.want)
+		}
+	}
+	if _, err := s.AST("x & -1"); err == nil {
+		t.Error("an operator without operand should fail to parse")
+	}
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.input = ":simplify 1 + (x | 0)"
+	c.handleEnter()
+	if c.message != "1 + (x | 0) simplifies to 1 + x" {
+		t.Errorf("message %q", c.message)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	s := calculator.NewState()
+	if err := s.Exec("40 + 2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expr := range []string{
+		"((x - 1) & x) ^ x", "-x * 3 + ~y", "x ** 3 % 1000", "x << 3 >> y", "popcount(x) + byte(y, 1)",
+		"x / y", "x % (y - y)", "x + $1 - ans", "abs(-2) * x", "(2*(3+2)-1)+1/1*3 + x",
+	} {
+		program, err := s.Compile(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		for _, values := range [][2]int64{{12, 5}, {-7, 2}, {0, 0}, {1 << 40, -3}} {
+			s.Variables["x"], s.Variables["y"] = values[0], values[1]
+			want, wantErr := s.Evaluate(expr)
+			got, err := program.Run(program.Values(s))
+			if got != want || (err == nil) != (wantErr == nil) {
+				t.Errorf("%s with x, y = %v: %d, %v, want %d, %v", expr, values, got, err, want, wantErr)
+			}
+		}
+	}
+	program, _ := s.Compile("popcount(x) + (x << 2 ^ y) * 3")
+	if !slices.Equal(program.Vars, []string{"x", "y"}) {
+		t.Errorf("variables %v", program.Vars)
+	}
+	values := []int64{7, 9}
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = program.Run(values) }); allocs != 0 {
+		t.Errorf("Run allocates %v times", allocs)
+	}
+	s.Width = 8
+	program, _ = s.Compile("x + 1")
+	if got, _ := program.Run([]int64{127}); got != -128 {
+		t.Errorf("127 + 1 on 8 bits = %d", got)
+	}
+	for _, expr := range []string{"y = x", "sqrt(x)", "1 / 0", "x -", "$9"} {
+		if _, err := s.Compile(expr); err == nil {
+			t.Errorf("%s should not compile", expr)
+		}
+	}
+}
+
+const benchmarkExpr = "((x - 1) & x) ^ x + popcount(x) * 3"
+
+func BenchmarkExec(b *testing.B) {
+	s := calculator.NewState()
+	b.ReportAllocs()
+	for i := range b.N {
+		s.Variables["x"] = int64(i)
+		if err := s.Exec(benchmarkExpr); err != nil {
+			b.Fatal(err)
+		}
+		s.History = nil
+	}
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	s := calculator.NewState()
+	b.ReportAllocs()
+	for i := range b.N {
+		s.Variables["x"] = int64(i)
+		if _, err := s.Evaluate(benchmarkExpr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgram(b *testing.B) {
+	program, err := calculator.NewState().Compile(benchmarkExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	values := make([]int64, 1)
+	b.ReportAllocs()
+	for i := range b.N {
+		values[0] = int64(i)
+		if _, err := program.Run(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMapMode(t *testing.T) {
+	for _, tt := range []struct {
+		args        []string
+		input       string
+		out, errOut string
+		status      int
+	}{
+		{[]string{"-base", "hex", "((x - 1) & x) ^ x"}, "12\n\n0x10\n'A'\n", "0x4\n0x10\n0x1\n", "", 0},
+		{[]string{"-header", "a << b"}, "a, b\n1, 2\n3,4\n5\n", "4\n48\n", "line 4: 1 values for 2 columns\n", 1},
+		{[]string{"x1 + x2 * x"}, "1 2\noops 4\n3 4\n", "3\n21\n", "line 2: oops: not a value\n", 1},
+		{[]string{"x1+x2+x3"}, "1 2 3\n4 5\n6\n", "6\n", "line 2: 2 values, the expression uses x3\nline 3: 1 values, the expression uses x3\n", 1},
+		{[]string{"-width", "8", "x + 1"}, "127\n", "-128\n", "", 0},
+		{[]string{"sqrt(x)"}, "9\n", "3\n", "", 0},
+		{[]string{"x +"}, "1\n", "", "missing operand near \"+\"\n", 2},
+	} {
+		var out, errOut bytes.Buffer
+		status := runMap(calculator.NewState(), tt.args, strings.NewReader(tt.input), &out, &errOut)
+		if status != tt.status || out.String() != tt.out || errOut.String() != tt.errOut {
+			t.Errorf("%q: status %d, output %q, errors %q", tt.args, status, out.String(), errOut.String())
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	var out, errOut bytes.Buffer
+	status := runTable(calculator.NewState(), []string{"-format", "csv", "a ^ b for a in 0..1, b in 0..1"}, &out, &errOut)
+	if want := "a,b,a ^ b,hex,bin\n0,0,0,0x0,0b0\n0,1,1,0x1,0b1\n1,0,1,0x1,0b1\n1,1,0,0x0,0b0\n"; status != 0 || out.String() != want {
+		t.Errorf("status %d, csv %q, errors %q", status, out.String(), errOut.String())
+	}
+	s := calculator.NewState()
+	for _, spec := range []string{"x + 1", "x for 1 in 0..3", "x for x in 3..0", "x for x in 0..1<<20", "x for x, y in 0..1", "x for x in 0..3, x in 0..3"} {
+		if _, err := parseSweep(s, spec); err == nil {
+			t.Errorf("%s should not parse", spec)
+		}
+	}
+	sw, _ := parseSweep(s, "8 / x | y for x in -1..1, y in 'a'..'b'")
+	cells := sw.cells(sw.evaluate(s))
+	if len(cells) != 7 || !slices.Equal(cells[3], []string{"0", "97", "error: division by zero", "", ""}) {
+		t.Errorf("cells %q", cells)
+	}
+	if md := tableFormats["markdown"](cells[:2]); md[0] != "| x | y | 8 / x \\| y | hex | bin |" || md[1] != "| --- | --- | --- | --- | --- |" {
+		t.Errorf("markdown %q", md)
+	}
+	c := configure(ansipixels.NewAnsiPixels(30))
+	c.AP.W, c.AP.H = 80, 12
+	c.input = ":table x * x for x in 0..15"
+	c.handleEnter()
+	if len(c.state.Variables) != 0 || c.view != tableView {
+		t.Errorf("the table shouldn't assign x: %v", c.state.Variables)
+	}
+	press := func(keys ...string) {
+		for _, key := range keys {
+			c.AP.Data = []byte(key)
+			c.handleInput()
+		}
+	}
+	press("\x1b[6~", "\x1b[6~", "\x1b[A")
+	display := c.tableDisplayStrings()
+	if c.table.scroll != 9 || !strings.HasPrefix(display[1], "Table of x * x, rows 10-15 of 16") ||
+		display[3] != "9   81     0x51  0b01010001" {
+		t.Errorf("scrolled to %d: %q", c.table.scroll, display)
+	}
+	c.state.Mode = calculator.RationalMode
+	c.state.Exec("1/3")
+	if display = c.relayout(); len(display) > c.layout.results.h || !strings.HasPrefix(display[2], "Table of x * x") {
+		t.Errorf("the exact result pushed the title out of %d rows: %q", c.layout.results.h, display)
+	}
+	press("\x1b", "9")
+	if c.table.focused || c.input != "9" {
+		t.Errorf("Esc should give the keys back to the input, input %q", c.input)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"fortio.org/terminal/ansipixels"
+	"fortio.org/terminal/ansipixels/tcolor"
+)
+
+// element is a part of the display that themes style.
+type element int
+
+const (
+	errorElement element = iota
+	setBitElement
+	clearBitElement
+	newlySetElement     // bits set since the previous result
+	newlyClearedElement // bits cleared since the previous result
+	operatorElement
+	selectionElement // underline of the selected history entry
+	dimElement       // hints such as the history scroll indicators
+	accentElement    // every other byte on the bit grid of the bytes and char views
+	signElement
+	exponentElement
+	mantissaElement
+	numElements
+)
+
+var elementNames = [numElements]string{
+	"error", "set", "clear", "newset", "newclear", "operator", "selection", "dim", "accent", "sign", "exponent", "mantissa",
+}
+
+func parseElement(name string) (element, error) {
+	i := slices.Index(elementNames[:], name)
+	if i == -1 {
+		return 0, errors.New("unknown element " + name + ", one of " + strings.Join(elementNames[:], " "))
+	}
+	return element(i), nil
+}
+
+// style is how a theme draws an element: basic on 16 color terminals, rich (any color
+// tcolor.FromString accepts) on 256 and truecolor ones when set, attr (bold, inverse…)
+// everywhere including monochrome.
+type style struct {
+	basic tcolor.BasicColor
+	rich  string
+	attr  string
+}
+
+type themeStyles [numElements]style
+
+var themes = map[string]themeStyles{
+	"dark": {
+		errorElement:        {basic: tcolor.Red, rich: "ff5f5f"},
+		setBitElement:       {rich: "eeeeee"},
+		clearBitElement:     {rich: "767676"},
+		newlySetElement:     {basic: tcolor.BrightGreen, rich: "87ff5f", attr: tcolor.Bold},
+		newlyClearedElement: {basic: tcolor.BrightRed, rich: "ff5f87", attr: tcolor.Bold},
+		operatorElement:     {basic: tcolor.Cyan, rich: "5fd7ff"},
+		selectionElement:    {basic: tcolor.Green, rich: "87d75f"},
+		dimElement:          {basic: tcolor.DarkGray, rich: "808080"},
+		accentElement:       {basic: tcolor.Cyan, rich: "5fafd7"},
+		signElement:         {basic: tcolor.Red, rich: "ff5f5f"},
+		exponentElement:     {basic: tcolor.Yellow, rich: "ffd75f"},
+		mantissaElement:     {basic: tcolor.Green, rich: "87d75f"},
+	},
+	"light": {
+		errorElement:        {basic: tcolor.Red, rich: "d70000"},
+		setBitElement:       {basic: tcolor.Black, rich: "000000"},
+		clearBitElement:     {basic: tcolor.DarkGray, rich: "a8a8a8"},
+		newlySetElement:     {basic: tcolor.Green, rich: "008700", attr: tcolor.Bold},
+		newlyClearedElement: {basic: tcolor.Red, rich: "d70000", attr: tcolor.Bold},
+		operatorElement:     {basic: tcolor.Blue, rich: "005fd7"},
+		selectionElement:    {basic: tcolor.Blue, rich: "0087af"},
+		dimElement:          {basic: tcolor.DarkGray, rich: "8a8a8a"},
+		accentElement:       {basic: tcolor.Blue, rich: "005f87"},
+		signElement:         {basic: tcolor.Red, rich: "af0000"},
+		exponentElement:     {basic: tcolor.Purple, rich: "875f00"},
+		mantissaElement:     {basic: tcolor.Green, rich: "005f00"},
+	},
+	"high-contrast": {
+		errorElement:        {basic: tcolor.BrightRed, attr: tcolor.Bold},
+		setBitElement:       {basic: tcolor.White, attr: tcolor.Bold},
+		clearBitElement:     {basic: tcolor.DarkGray},
+		newlySetElement:     {basic: tcolor.BrightGreen, attr: tcolor.Inverse},
+		newlyClearedElement: {basic: tcolor.BrightRed, attr: tcolor.Inverse},
+		operatorElement:     {basic: tcolor.BrightCyan, attr: tcolor.Bold},
+		selectionElement:    {basic: tcolor.BrightGreen, attr: tcolor.Bold},
+		dimElement:          {basic: tcolor.Gray},
+		accentElement:       {basic: tcolor.BrightCyan},
+		signElement:         {basic: tcolor.BrightRed, attr: tcolor.Bold},
+		exponentElement:     {basic: tcolor.BrightYellow, attr: tcolor.Bold},
+		mantissaElement:     {basic: tcolor.BrightGreen, attr: tcolor.Bold},
+	},
+	"monochrome": {
+		errorElement:        {attr: tcolor.Bold},
+		setBitElement:       {attr: tcolor.Bold},
+		clearBitElement:     {attr: tcolor.Dim},
+		newlySetElement:     {attr: tcolor.Inverse},
+		newlyClearedElement: {attr: tcolor.Underlined},
+		operatorElement:     {attr: tcolor.Bold},
+		selectionElement:    {attr: tcolor.Bold},
+		dimElement:          {attr: tcolor.Dim},
+		accentElement:       {attr: tcolor.Underlined},
+		signElement:         {attr: tcolor.Bold},
+		exponentElement:     {attr: tcolor.Underlined},
+	},
+}
+
+func themeNames() []string {
+	return slices.Sorted(maps.Keys(themes))
+}
+
+// colorDepth is what the terminal can display.
+type colorDepth int
+
+const (
+	noColors colorDepth = iota // NO_COLOR is set, only attributes are used
+	basicColors
+	colors256
+	trueColors
+)
+
+// detectColorDepth honours NO_COLOR and otherwise uses what ansipixels detected from
+// COLORTERM and TERM.
+func detectColorDepth(mode ansipixels.ColorMode) colorDepth {
+	switch {
+	case os.Getenv("NO_COLOR") != "":
+		return noColors
+	case mode.TrueColor:
+		return trueColors
+	case mode.Color256:
+		return colors256
+	default:
+		return basicColors
+	}
+}
+
+// render returns the escape sequences starting each element.
+func (t themeStyles) render(depth colorDepth) [numElements]string {
+	var codes [numElements]string
+	output := tcolor.ColorOutput{TrueColor: depth == trueColors}
+	for e, s := range t {
+		code := s.attr
+		if rich, err := tcolor.FromString(s.rich); err == nil && s.rich != "" && depth >= colors256 {
+			code += output.Foreground(rich)
+		} else if s.basic != tcolor.None && depth >= basicColors {
+			code += s.basic.Foreground()
+		}
+		codes[e] = code
+	}
+	return codes
+}
+
+// colors are the escape sequences of the current theme, see config.applyTheme.
+var colors = themes["dark"].render(basicColors)
+
+// paint draws s in the color of e.
+func paint(e element, s string) string {
+	if colors[e] == "" {
+		return s
+	}
+	return colors[e] + s + tcolor.Reset
+}
+
+// setTheme switches to a named theme, dropping the colors set with :color.
+func (c *config) setTheme(name string) error {
+	styles, ok := themes[name]
+	if !ok {
+		return errors.New("unknown theme " + name + ", one of " + strings.Join(themeNames(), " "))
+	}
+	c.themeName, c.theme = name, styles
+	c.applyTheme()
+	return nil
+}
+
+// setColor changes the color of one element of the current theme, "none" removes it.
+func (c *config) setColor(name, color string) error {
+	e, err := parseElement(name)
+	if err != nil {
+		return err
+	}
+	s := style{attr: c.theme[e].attr}
+	if color != "none" {
+		if _, err := tcolor.FromString(color); err != nil {
+			return err
+		}
+		s.basic = tcolor.ColorMap[strings.ToLower(color)] // None unless it is a basic color name
+		s.rich = color
+	}
+	c.theme[e] = s
+	c.applyTheme()
+	return nil
+}
+
+func (c *config) applyTheme() {
+	colors = c.theme.render(c.colorDepth)
+}
+
+// highlightInput colors the operators of the input line, leaving character and
+// string literals alone.
+func highlightInput(input string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range input {
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case strings.ContainsRune("+-*/%&|^~<>=", r):
+			b.WriteString(paint(operatorElement, string(r)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+
+	"fortio.org/terminal/ansipixels/tcolor"
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// variablesPanel lists State.Variables. F2 shows and focuses it, then the arrows move
+// the selection, enter inserts the name in the input line, e edits the variable, d
+// deletes it, s changes the order and b the base.
+type variablesPanel struct {
+	shown, focused bool
+	selected       int // index in sortedVariables
+	scroll         int // first variable shown
+	byValue        bool
+	base           int
+}
+
+var variablesBases = []int{16, 10, 2, 8}
+
+// sortedVariables returns the variable names by name or by value.
+func (c *config) sortedVariables() []string {
+	names := slices.Sorted(maps.Keys(c.state.Variables))
+	if c.vars.byValue {
+		slices.SortStableFunc(names, func(a, b string) int {
+			return cmp.Compare(c.state.Variables[a], c.state.Variables[b])
+		})
+	}
+	return names
+}
+
+// variablesLines is the height of the variables panel, a title and one line per variable.
+func (c *config) variablesLines() int {
+	if !c.vars.shown {
+		return 0
+	}
+	return max(1, len(c.state.Variables)) + 1
+}
+
+// selectedVariable returns the name of the selected variable, clamping the selection.
+func (c *config) selectedVariable() (string, bool) {
+	names := c.sortedVariables()
+	if len(names) == 0 {
+		c.vars.selected = 0
+		return "", false
+	}
+	c.vars.selected = max(0, min(c.vars.selected, len(names)-1))
+	return names[c.vars.selected], true
+}
+
+// toggleVariables is F2: show and focus the panel, or hide it when it has the focus.
+func (c *config) toggleVariables() {
+	if c.vars.focused {
+		c.vars.shown, c.vars.focused = false, false
+		return
+	}
+	c.vars.shown, c.vars.focused = true, true
+}
+
+// handleVariablesKey handles the keys while the variables panel has the focus.
+func (c *config) handleVariablesKey(key string) {
+	name, ok := c.selectedVariable()
+	switch key {
+	case "\x1b": // escape
+		c.vars.focused = false
+	case "\x1bOQ", "\x1b[12~": // F2
+		c.toggleVariables()
+	case "\x1b[A": // up
+		c.vars.selected = max(0, c.vars.selected-1)
+	case "\x1b[B": // down
+		c.vars.selected++
+	case "s":
+		c.vars.byValue = !c.vars.byValue
+	case "b":
+		i := slices.Index(variablesBases, c.vars.base)
+		c.vars.base = variablesBases[(i+1)%len(variablesBases)]
+	case "\r", "\n":
+		if ok {
+			c.insert(name)
+			c.vars.focused = false
+		}
+	case "e":
+		if ok {
+			c.input = name + " = " + c.formatVariable(name)
+			c.index = len(c.input)
+			c.vars.focused = false
+		}
+	case "d", "\x1b[3~":
+		if ok {
+			c.state.DeleteVariable(name)
+			c.refreshWatches()
+			c.message = "deleted " + name
+		}
+	}
+	c.selectedVariable()
+}
+
+// formatVariable writes the value of a variable in the base of the panel, the exact
+// value when it was assigned in float or rational mode and shown in decimal.
+func (c *config) formatVariable(name string) string {
+	if exact, ok := c.state.Reals[name]; ok && c.vars.base == 10 {
+		if c.state.Mode == calculator.RationalMode || exact.IsInt() {
+			return exact.RatString()
+		}
+		f, _ := exact.Float64()
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return formatInt(c.state.Variables[name], c.vars.base)
+}
+
+// variableAt returns the variable drawn at the 1-based mouse coordinates.
+func (c *config) variableAt(x, y int) (string, bool) {
+	pane := c.layout.variables
+	if pane.empty() || !pane.contains(x-1, y-1) || y-1 == pane.y {
+		return "", false
+	}
+	names := c.sortedVariables()
+	i := c.vars.scroll + y - 1 - pane.y - 1
+	if i >= len(names) {
+		return "", false
+	}
+	return names[i], true
+}
+
+func (c *config) drawVariables() {
+	pane := c.layout.variables
+	if pane.empty() {
+		return
+	}
+	x := pane.x
+	if c.layout.sideHistory {
+		x++
+	}
+	order := "name"
+	if c.vars.byValue {
+		order = "value"
+	}
+	title := "Variables (F2) by " + order + " in base " + strconv.Itoa(c.vars.base)
+	c.AP.WriteAtStr(x, pane.y, paint(dimElement, clip(title, pane.w)))
+	names := c.sortedVariables()
+	if len(names) == 0 {
+		c.AP.WriteAtStr(x, pane.y+1, clip("none, assign with name = value", pane.w))
+		return
+	}
+	rows := pane.h - 1
+	c.vars.scroll = max(0, min(c.vars.scroll, len(names)-rows))
+	switch {
+	case c.vars.selected < c.vars.scroll:
+		c.vars.scroll = c.vars.selected
+	case c.vars.selected >= c.vars.scroll+rows:
+		c.vars.scroll = c.vars.selected - rows + 1
+	}
+	for i, name := range names[c.vars.scroll:min(len(names), c.vars.scroll+rows)] {
+		line := clip(name+" = "+c.formatVariable(name), pane.w-1)
+		if c.vars.focused && c.vars.scroll+i == c.vars.selected {
+			line = paint(selectionElement, tcolor.Inverse+line)
+		}
+		c.AP.WriteAtStr(x, pane.y+1+i, line)
+	}
+}
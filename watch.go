@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/geofpwhite/tcalc/calculator"
+)
+
+// watch is an expression pinned with :watch, evaluated again after every Exec.
+type watch struct {
+	expr  string
+	value int64
+	err   error
+}
+
+// addWatch refuses expressions that don't parse and assignments, which would run again
+// after every input.
+func (c *config) addWatch(expr string) error {
+	node, err := c.state.AST(expr)
+	if err != nil {
+		return err
+	}
+	if node.Kind == calculator.Assign {
+		return errors.New("watches can't assign " + node.Name)
+	}
+	c.watches = append(c.watches, watch{expr: expr})
+	c.refreshWatches()
+	return nil
+}
+
+// removeWatch removes the watch numbered n (from 1) or all of them.
+func (c *config) removeWatch(arg string) error {
+	if arg == "all" {
+		c.watches = nil
+		return nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(c.watches) {
+		return errors.New("usage: :unwatch n|all, n from 1 to " + strconv.Itoa(len(c.watches)))
+	}
+	c.watches = append(c.watches[:n-1], c.watches[n:]...)
+	return nil
+}
+
+func (c *config) refreshWatches() {
+	for i := range c.watches {
+		w := &c.watches[i]
+		w.value, w.err = c.state.Evaluate(w.expr)
+	}
+}
+
+// watchLines is the height of the watch panel, a title and one line per watch.
+func (c *config) watchLines() int {
+	if len(c.watches) == 0 {
+		return 0
+	}
+	return len(c.watches) + 1
+}
+
+func (w watch) String() string {
+	if w.err != nil {
+		return w.expr + ": " + w.err.Error()
+	}
+	bits := uint64(w.value) //nolint:gosec // bit pattern
+	return fmt.Sprintf("%s = %d  %#x  %#o  %#b", w.expr, w.value, bits, bits, bits)
+}
+
+func (c *config) drawWatches() {
+	pane := c.layout.watch
+	if pane.empty() {
+		return
+	}
+	x := pane.x
+	if c.layout.sideHistory {
+		x++
+	}
+	c.AP.WriteAtStr(x, pane.y, paint(dimElement, clip("Watch (:unwatch n)", pane.w)))
+	for i, w := range c.watches[:pane.h-1] {
+		line := clip(strconv.Itoa(i+1)+" "+w.String(), pane.w-1)
+		if w.err != nil {
+			line = paint(errorElement, line)
+		}
+		c.AP.WriteAtStr(x, pane.y+1+i, line)
+	}
+}